@@ -48,6 +48,12 @@
   [[ "$output" =~ "data.kubernetes.is_service" ]]
 }
 
+@test "Can parse files to JSON with the parse command" {
+  run ./conftest parse examples/docker/Dockerfile
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "\"Cmd\": \"from\"" ]]
+}
+
 @test "Has help flag" {
   run ./conftest --help
   [ "$status" -eq 0 ]
@@ -75,6 +81,43 @@
   [[ "$output" =~ "The image port should be 8080 in deployment.cue. you got : 8081" ]]
 }
 
+@test "Can evaluate cue files with the rest of their package" {
+  run ./conftest parse examples/cue/deployment.cue
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "\"kind\": \"Deployment\"" ]]
+}
+
+@test "Can render and test helm charts" {
+  run ./conftest test -p examples/helm/policy --helm-chart examples/helm/chart
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "hello/templates/deployment.yaml - Containers must not run as root in Deployment release-name-hello" ]]
+}
+
+@test "Can render helm charts with values files" {
+  run ./conftest test -p examples/helm/policy --helm-chart examples/helm/chart -f examples/helm/values-production.yaml
+  [ "$status" -eq 0 ]
+}
+
+@test "Can build and test kustomizations" {
+  run ./conftest test -p examples/kustomize/policy examples/kustomize
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "examples/kustomize/deployment.yaml (Deployment/the-deployment) - Containers must not run as root" ]]
+  [[ "$output" =~ "examples/kustomize/service.yaml (Service/the-service) - Services are not allowed" ]]
+}
+
+@test "Can parse docker compose files with their overrides" {
+  run ./conftest test -i compose -p examples/compose/policy examples/compose/docker-compose.yml
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "Service web must not mount the Docker socket" ]]
+}
+
+@test "Can parse cloudformation templates with intrinsic functions" {
+  run ./conftest test -i cloudformation -p examples/cloudformation/policy examples/cloudformation/template.yaml
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "Bucket LogBucket must not be public" ]]
+  [[ ! "$output" =~ "must reference a bucket" ]]
+}
+
 @test "Can parse ini files" {
   run ./conftest test -p examples/ini/policy examples/ini/grafana.ini
   [ "$status" -eq 1 ]
@@ -98,6 +141,18 @@
   [ "$status" -eq 0 ]
 }
 
+@test "Can parse xml files" {
+  run ./conftest test -p examples/xml/policy examples/xml/pom.xml
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "log4j 1.2.17 is end of life" ]]
+}
+
+@test "Can parse properties files" {
+  run ./conftest test --nest-properties -p examples/properties/policy examples/properties/application.properties
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "The env actuator endpoint should not be exposed" ]]
+}
+
 @test "Can parse docker files" {
   run ./conftest test -p examples/docker/policy examples/docker/Dockerfile
   [ "$status" -eq 1 ]
@@ -0,0 +1,16 @@
+package main
+
+
+deny[msg] {
+  bucket := input.Resources[_]
+  bucket.Type = "AWS::S3::Bucket"
+  startswith(bucket.Properties.AccessControl, "Public")
+  msg = sprintf("Bucket %s must not be public", [bucket.LogicalId])
+}
+
+deny[msg] {
+  policy := input.Resources[_]
+  policy.Type = "AWS::S3::BucketPolicy"
+  not policy.Properties.Bucket.Ref
+  msg = sprintf("Bucket policy %s must reference a bucket in the template", [policy.LogicalId])
+}
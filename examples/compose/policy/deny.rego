@@ -15,3 +15,10 @@ deny[msg] {
   version < 3.5
   msg = "Must be using at least version 3.5 of the Compose file format"
 }
+
+deny[msg] {
+  volume := input.services[name].volumes[_]
+  volume.type = "bind"
+  volume.source = "/var/run/docker.sock"
+  msg = sprintf("Service %s must not mount the Docker socket", [name])
+}
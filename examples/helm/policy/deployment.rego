@@ -0,0 +1,13 @@
+package main
+
+deny[msg] {
+  input.kind = "Deployment"
+  not input.spec.template.spec.securityContext.runAsNonRoot = true
+  msg = sprintf("Containers must not run as root in Deployment %s", [input.metadata.name])
+}
+
+warn[msg] {
+  input.kind = "Deployment"
+  input.spec.replicas < 2
+  msg = sprintf("Deployment %s should run more than one replica", [input.metadata.name])
+}
@@ -0,0 +1,12 @@
+package main
+
+deny[msg] {
+  input.server.ssl.enabled != "true"
+  msg = "Server should have TLS enabled"
+}
+
+deny[msg] {
+  endpoints = split(input.management.endpoints.web.exposure.include, ",")
+  endpoints[_] = "env"
+  msg = "The env actuator endpoint should not be exposed"
+}
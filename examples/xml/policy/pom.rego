@@ -0,0 +1,17 @@
+package main
+
+dependencies[dependency] {
+  dependency = input.project.dependencies.dependency[_]
+}
+
+deny[msg] {
+  dependency = dependencies[_]
+  dependency.artifactId = "log4j"
+  startswith(dependency.version, "1.")
+  msg = sprintf("log4j %s is end of life, upgrade to log4j 2", [dependency.version])
+}
+
+warn[msg] {
+  endswith(input.project.version, "-SNAPSHOT")
+  msg = "Released artifacts should not use a SNAPSHOT version"
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/instrumenta/conftest/pkg/commands/inspect"
+	"github.com/instrumenta/conftest/pkg/commands/parse"
 	"github.com/instrumenta/conftest/pkg/commands/pull"
 	"github.com/instrumenta/conftest/pkg/commands/push"
+	"github.com/instrumenta/conftest/pkg/commands/repl"
 	"github.com/instrumenta/conftest/pkg/commands/test"
 	"github.com/instrumenta/conftest/pkg/commands/update"
 	"github.com/instrumenta/conftest/pkg/constants"
@@ -50,6 +53,9 @@ func NewDefaultCommand() *cobra.Command {
 	cmd.AddCommand(update.NewUpdateCommand())
 	cmd.AddCommand(push.NewPushCommand())
 	cmd.AddCommand(pull.NewPullCommand())
+	cmd.AddCommand(inspect.NewInspectCommand())
+	cmd.AddCommand(parse.NewParseCommand())
+	cmd.AddCommand(repl.NewREPLCommand())
 
 	if viper.GetBool("debug") {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -0,0 +1,112 @@
+package inspect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/instrumenta/conftest/pkg/policy"
+
+	"github.com/containerd/containerd/log"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+)
+
+// NewInspectCommand creates a new inspect command
+func NewInspectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect <repository>",
+		Short: "Show the contents of a remote policy bundle",
+		Long:  `Show the files and annotations of a policy bundle in an OCI registry without downloading it, or list the tags of a repository`,
+		Args:  cobra.ExactArgs(1),
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			policy.BindRegistryFlags(cmd)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+
+			options, err := policy.RegistryOptionsFromConfig(os.Stdin)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			listTags, err := cmd.Flags().GetBool("tags")
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			if listTags {
+				tags, err := policy.ListTags(ctx, options, args[0])
+				if err != nil {
+					log.G(ctx).Fatal(err)
+				}
+
+				for _, tag := range tags {
+					fmt.Println(tag)
+				}
+				return
+			}
+
+			resolver, err := policy.NewResolver(options, args[0])
+			if err != nil {
+				log.G(ctx).Fatalf("Error loading resolver: %v\n", err)
+			}
+
+			ref, manifestDigest, manifest, err := policy.FetchManifest(ctx, resolver, args[0])
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			if err := writeManifest(os.Stdout, ref, manifestDigest, manifest); err != nil {
+				log.G(ctx).Fatalf("Problem writing manifest: %v", err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolP("tags", "", false, "list the tags of the repository instead of inspecting a bundle")
+	policy.AddRegistryFlags(cmd)
+
+	return cmd
+}
+
+// writeManifest prints the reference and digest of a bundle, followed by a
+// table of its layers and a table of its annotations
+func writeManifest(w io.Writer, ref string, manifestDigest digest.Digest, manifest ocispec.Manifest) error {
+	fmt.Fprintf(w, "Reference: %s\n", ref)
+	fmt.Fprintf(w, "Digest: %s\n", manifestDigest)
+	fmt.Fprintf(w, "Config: %s\n\n", manifest.Config.MediaType)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "FILE\tMEDIA TYPE\tSIZE\tDIGEST")
+	for _, layer := range manifest.Layers {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", layer.Annotations[ocispec.AnnotationTitle], layer.MediaType, layer.Size, layer.Digest)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if len(manifest.Annotations) == 0 {
+		return nil
+	}
+
+	var keys []string
+	for key := range manifest.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w)
+	table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ANNOTATION\tVALUE")
+	for _, key := range keys {
+		fmt.Fprintf(table, "%s\t%s\n", key, manifest.Annotations[key])
+	}
+
+	return table.Flush()
+}
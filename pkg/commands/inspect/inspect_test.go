@@ -0,0 +1,49 @@
+package inspect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestWriteManifest(t *testing.T) {
+	manifest := ocispec.Manifest{
+		Config: ocispec.Descriptor{MediaType: constants.OpenPolicyAgentConfigMediaType},
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType:   constants.OpenPolicyAgentPolicyLayerMediaType,
+				Digest:      digest.FromString("package main"),
+				Size:        12,
+				Annotations: map[string]string{ocispec.AnnotationTitle: "main.rego"},
+			},
+		},
+		Annotations: map[string]string{
+			ocispec.AnnotationSource:  "https://github.com/instrumenta/policies",
+			ocispec.AnnotationCreated: "2019-06-01T00:00:00Z",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, "localhost:5000/policies:latest", digest.FromString("manifest"), manifest); err != nil {
+		t.Fatalf("writing the manifest should not have thrown an error: %v", err)
+	}
+
+	expected := "Reference: localhost:5000/policies:latest\n" +
+		"Digest: " + digest.FromString("manifest").String() + "\n" +
+		"Config: " + constants.OpenPolicyAgentConfigMediaType + "\n" +
+		"\n" +
+		"FILE       MEDIA TYPE" + strings.Repeat(" ", len(constants.OpenPolicyAgentPolicyLayerMediaType)-len("MEDIA TYPE")+2) + "SIZE  DIGEST\n" +
+		"main.rego  " + constants.OpenPolicyAgentPolicyLayerMediaType + "  12    " + digest.FromString("package main").String() + "\n" +
+		"\n" +
+		"ANNOTATION                        VALUE\n" +
+		"org.opencontainers.image.created  2019-06-01T00:00:00Z\n" +
+		"org.opencontainers.image.source   https://github.com/instrumenta/policies\n"
+
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/commands/test"
+	"github.com/instrumenta/conftest/pkg/parser"
+
+	"github.com/containerd/containerd/log"
+	"github.com/spf13/cobra"
+)
+
+// NewParseCommand creates a new parse command
+func NewParseCommand() *cobra.Command {
+	ctx := context.Background()
+	cmd := &cobra.Command{
+		Use:   "parse <file> [file...]",
+		Short: "Print the input document for your configuration files",
+		Long:  `Print the parsed configuration files as JSON, as they will be seen by Rego policies through input`,
+		Args:  cobra.MinimumNArgs(1),
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			test.BindParserFlags(cmd)
+		},
+
+		Run: func(cmd *cobra.Command, fileList []string) {
+			input, err := cmd.Flags().GetString("input")
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+			combine, err := cmd.Flags().GetBool(test.CombineConfigFlagName)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			configurations, err := test.GetConfigurations(input, fileList)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			out, err := parseConfigurations(configurations, combine)
+			if err != nil {
+				log.G(ctx).Fatalf("Problem generating output: %s", err)
+			}
+
+			fmt.Println(out)
+		},
+	}
+
+	cmd.Flags().BoolP(test.CombineConfigFlagName, "", false, "combine all given config files into a single input document")
+	cmd.Flags().StringP("input", "i", "", fmt.Sprintf("input type for given source, especially useful when using conftest with stdin, valid options are: %s", parser.ValidInputs()))
+	test.AddParserFlags(cmd)
+
+	return cmd
+}
+
+// parseConfigurations renders the parsed configurations as indented JSON.
+// When combine is set the configurations are rendered as a single document
+// keyed by file name, matching what the test command passes to Rego.
+func parseConfigurations(configurations map[string]interface{}, combine bool) (string, error) {
+	if combine {
+		return formatJSON(configurations)
+	}
+
+	var fileNames []string
+	for fileName := range configurations {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	var out []string
+	for _, fileName := range fileNames {
+		contents, err := formatJSON(configurations[fileName])
+		if err != nil {
+			return "", err
+		}
+
+		out = append(out, fmt.Sprintf("%s\n%s", fileName, contents))
+	}
+
+	return strings.Join(out, "\n\n"), nil
+}
+
+func formatJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("Unable to marshal input to JSON: %s", err)
+	}
+
+	var out bytes.Buffer
+	err = json.Indent(&out, b, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,61 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestParseConfigurations(t *testing.T) {
+	configurations := map[string]interface{}{
+		"service.yaml": map[string]interface{}{
+			"kind": "Service",
+		},
+		"deployment.yaml": map[string]interface{}{
+			"kind": "Deployment",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		combine  bool
+		expected string
+	}{
+		{
+			name:    "each file is printed separately",
+			combine: false,
+			expected: `deployment.yaml
+{
+	"kind": "Deployment"
+}
+
+service.yaml
+{
+	"kind": "Service"
+}`,
+		},
+		{
+			name:    "combined files are printed as a single document",
+			combine: true,
+			expected: `{
+	"deployment.yaml": {
+		"kind": "Deployment"
+	},
+	"service.yaml": {
+		"kind": "Service"
+	}
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := parseConfigurations(configurations, test.combine)
+			if err != nil {
+				t.Fatalf("parsing configurations should not have thrown an error: %v", err)
+			}
+
+			if actual != test.expected {
+				t.Errorf("Expected\n%v\ngot\n%v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/instrumenta/conftest/pkg/policy"
 
+	"github.com/containerd/containerd/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // NewPullCommand creates a new pull command
@@ -16,11 +18,25 @@ func NewPullCommand() *cobra.Command {
 		Long:  `Download individual policies from a registry`,
 		Args:  cobra.MinimumNArgs(1),
 
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlag("verify-key", cmd.Flags().Lookup("verify-key"))
+			viper.BindPFlag("clean", cmd.Flags().Lookup("clean"))
+			viper.BindPFlag("offline", cmd.Flags().Lookup("offline"))
+			viper.BindPFlag("cache-ttl", cmd.Flags().Lookup("cache-ttl"))
+			policy.BindRegistryFlags(cmd)
+		},
+
 		Run: func(cmd *cobra.Command, args []string) {
 			RunPullCommand(args)
 		},
 	}
 
+	cmd.Flags().BoolP("offline", "", false, "use cached policies without contacting their source")
+	cmd.Flags().DurationP("cache-ttl", "", 0, "reuse cached policies downloaded within this duration")
+	cmd.Flags().BoolP("clean", "", false, "remove the directory of each policy before writing it")
+	cmd.Flags().StringSliceP("verify-key", "", []string{}, "path to a PEM encoded public key used to verify policy signatures")
+	policy.AddRegistryFlags(cmd)
+
 	return cmd
 }
 
@@ -29,7 +45,9 @@ func RunPullCommand(repositories []string) {
 	policies := getPolicies(repositories)
 
 	ctx := context.Background()
-	policy.DownloadPolicy(ctx, policies)
+	if _, err := policy.DownloadPolicy(ctx, policies); err != nil {
+		log.G(ctx).Fatal(err)
+	}
 }
 
 func getPolicies(repositories []string) []policy.Policy {
@@ -2,18 +2,25 @@ package push
 
 import (
 	"context"
-	"io/ioutil"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/instrumenta/conftest/pkg/constants"
+	"github.com/instrumenta/conftest/pkg/policy"
 
 	"github.com/containerd/containerd/log"
-	"github.com/containerd/containerd/remotes/docker"
-	auth "github.com/deislabs/oras/pkg/auth/docker"
 	"github.com/deislabs/oras/pkg/content"
 	"github.com/deislabs/oras/pkg/oras"
+	"github.com/open-policy-agent/opa/ast"
+	digest "github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
 )
@@ -22,10 +29,14 @@ import (
 func NewPushCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "push <repository> [filepath]",
-		Short: "Upload OPA bundles to an OCI registry",
-		Long:  `Upload Open Policy Agent bundles to an OCI registry`,
+		Short: "Upload OPA bundles to an OCI registry or a bundle server",
+		Long:  `Upload Open Policy Agent bundles to an OCI registry, or as a bundle tarball to an http://, https:// or file:// URL`,
 		Args:  cobra.RangeArgs(1, 2),
 
+		PreRun: func(cmd *cobra.Command, args []string) {
+			policy.BindRegistryFlags(cmd)
+		},
+
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 
@@ -40,24 +51,54 @@ func NewPushCommand() *cobra.Command {
 				}
 			}
 
-			uploadBundle(ctx, args[0], path)
+			signKey, err := cmd.Flags().GetString("sign-key")
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			if policy.IsBundleURL(args[0]) {
+				if signKey != "" {
+					log.G(ctx).Fatal("Bundle tarballs cannot be signed, --sign-key is only supported for OCI registries")
+				}
+
+				pushBundleTarball(ctx, args[0], path)
+				return
+			}
+
+			extraAnnotations, err := cmd.Flags().GetStringArray("annotation")
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			annotations, err := bundleAnnotations(path, extraAnnotations)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			uploadBundle(ctx, args[0], path, signKey, annotations)
 		},
 	}
 
+	cmd.Flags().StringP("sign-key", "", "", "path to a PEM encoded private key used to sign the bundle")
+	cmd.Flags().StringArrayP("annotation", "", []string{}, "annotation to add to the bundle manifest, as key=value")
+	policy.AddRegistryFlags(cmd)
+
 	return cmd
 }
 
-func uploadBundle(ctx context.Context, repository string, root string) {
-
-	cli, err := auth.NewClient()
-	if err != nil {
-		log.G(ctx).Warnf("Error loading auth file: %v\n", err)
+func uploadBundle(ctx context.Context, repository string, root string, signKey string, annotations map[string]string) {
+	var signer crypto.Signer
+	if signKey != "" {
+		var err error
+		signer, err = policy.LoadPrivateKey(signKey)
+		if err != nil {
+			log.G(ctx).Fatalf("Error loading signing key: %v\n", err)
+		}
 	}
 
-	resolver, err := cli.Resolver(ctx)
+	options, err := policy.RegistryOptionsFromConfig(os.Stdin)
 	if err != nil {
-		log.G(ctx).Warnf("Error loading resolver: %v\n", err)
-		resolver = docker.NewResolver(docker.ResolverOptions{})
+		log.G(ctx).Fatal(err)
 	}
 
 	var ref string
@@ -67,81 +108,196 @@ func uploadBundle(ctx context.Context, repository string, root string) {
 		ref = repository + ":latest"
 	}
 
+	resolver, err := policy.NewResolver(options, ref)
+	if err != nil {
+		log.G(ctx).Fatalf("Error loading resolver: %v\n", err)
+	}
+
 	layers, memoryStore := buildLayers(ctx, root)
+	config := buildConfig(ctx, memoryStore, layers)
 
 	log.G(ctx).Infof("Pushing bundle to %s\n", ref)
-	extraOpts := []oras.PushOpt{oras.WithConfigMediaType(constants.OpenPolicyAgentConfigMediaType)}
+	extraOpts := []oras.PushOpt{
+		oras.WithConfig(config),
+		oras.WithManifestAnnotations(annotations),
+	}
 
 	manifest, err := oras.Push(ctx, resolver, ref, memoryStore, layers, extraOpts...)
 	if err != nil {
-		log.G(ctx).Fatal(err)
+		log.G(ctx).Fatal(policy.RegistryError(ref, err))
 	}
 
 	log.G(ctx).Infof("Pushed bundle to %s with digest %s\n", ref, manifest.Digest)
+
+	if signer != nil {
+		signatureRef, err := policy.PushSignature(ctx, resolver, ref, manifest.Digest, signer)
+		if err != nil {
+			log.G(ctx).Fatal(policy.RegistryError(ref, err))
+		}
+
+		log.G(ctx).Infof("Pushed signature to %s\n", signatureRef)
+	}
 }
 
-func buildLayers(ctx context.Context, root string) ([]ocispec.Descriptor, *content.Memorystore) {
-	var data []string
-	var policy []string
-	var layers []ocispec.Descriptor
-	var err error
+// pushBundleTarball writes the bundle as an Open Policy Agent bundle tarball to
+// a file:// URL or uploads it to an http:// or https:// URL
+func pushBundleTarball(ctx context.Context, destination string, root string) {
+	layers, memoryStore := buildLayers(ctx, root)
 
-	root, err = filepath.Abs(root)
+	log.G(ctx).Infof("Pushing bundle to %s\n", destination)
+	tarball, err := policy.PushBundle(ctx, destination, memoryStore, layers)
 	if err != nil {
 		log.G(ctx).Fatal(err)
 	}
 
-	info, err := os.Stat(root)
+	log.G(ctx).Infof("Pushed bundle to %s with digest %s\n", destination, tarball)
+}
+
+func buildLayers(ctx context.Context, root string) ([]ocispec.Descriptor, *content.Memorystore) {
+	memoryStore, layers, err := policy.DirectoryLayers(root, false)
 	if err != nil {
 		log.G(ctx).Fatal(err)
 	}
 
-	if !info.IsDir() {
-		log.G(ctx).Fatalf("%s isn't a directory", root)
+	manifestLayer := buildManifestLayer(ctx, memoryStore, layers)
+	layers = append(layers, manifestLayer)
+
+	return layers, memoryStore
+}
+
+// buildManifestLayer describes the bundle in the format of an Open Policy Agent
+// bundle manifest. The roots are the packages of the policies and the
+// directories of the data files.
+func buildManifestLayer(ctx context.Context, memoryStore *content.Memorystore, layers []ocispec.Descriptor) ocispec.Descriptor {
+	var roots []string
+	addRoot := func(root string) {
+		for _, r := range roots {
+			if r == root {
+				return
+			}
+		}
+		roots = append(roots, root)
+	}
+
+	for _, layer := range layers {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		if layer.MediaType != constants.OpenPolicyAgentPolicyLayerMediaType {
+			if dir := path.Dir(name); dir != "." {
+				addRoot(dir)
+			}
+			continue
+		}
+
+		_, contents, _ := memoryStore.Get(layer)
+		module, err := ast.ParseModule(name, string(contents))
+		if err != nil {
+			log.G(ctx).Fatalf("Unable to parse policy %s: %v", name, err)
+		}
+
+		addRoot(policy.PackageRoot(module))
 	}
 
-	memoryStore := content.NewMemoryStore()
+	sort.Strings(roots)
+	manifest, err := json.Marshal(policy.Manifest{Roots: roots})
+	if err != nil {
+		log.G(ctx).Fatal(err)
+	}
+
+	return memoryStore.Add(policy.ManifestFileName, constants.OpenPolicyAgentManifestLayerMediaType, manifest)
+}
 
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
+// buildConfig describes the namespaces and rules of the policies in the
+// bundle, and stores the description in the memory store as the config blob
+func buildConfig(ctx context.Context, memoryStore *content.Memorystore, layers []ocispec.Descriptor) ocispec.Descriptor {
+	rules := map[string]map[string]bool{}
+	for _, layer := range layers {
+		if layer.MediaType != constants.OpenPolicyAgentPolicyLayerMediaType {
+			continue
 		}
-		if filepath.Ext(path) == ".rego" {
-			policy = append(policy, path)
+
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		_, contents, _ := memoryStore.Get(layer)
+		module, err := ast.ParseModule(name, string(contents))
+		if err != nil {
+			log.G(ctx).Fatalf("Unable to parse policy %s: %v", name, err)
+		}
+
+		namespace := strings.TrimPrefix(module.Package.Path.String(), "data.")
+		if rules[namespace] == nil {
+			rules[namespace] = map[string]bool{}
 		}
-		if filepath.Ext(path) == ".json" {
-			data = append(data, path)
+		for _, rule := range module.Rules {
+			rules[namespace][rule.Head.Name.String()] = true
 		}
-		return nil
+	}
+
+	config := policy.Config{Namespaces: []policy.Namespace{}}
+	for namespace, names := range rules {
+		ns := policy.Namespace{Name: namespace, Rules: []string{}}
+		for name := range names {
+			ns.Rules = append(ns.Rules, name)
+		}
+		sort.Strings(ns.Rules)
+		config.Namespaces = append(config.Namespaces, ns)
+	}
+	sort.Slice(config.Namespaces, func(i, j int) bool {
+		return config.Namespaces[i].Name < config.Namespaces[j].Name
 	})
+
+	contents, err := json.Marshal(config)
 	if err != nil {
 		log.G(ctx).Fatal(err)
 	}
 
-	policyLayers := buildLayer(ctx, policy, root, memoryStore, constants.OpenPolicyAgentPolicyLayerMediaType)
-	dataLayers := buildLayer(ctx, data, root, memoryStore, constants.OpenPolicyAgentDataLayerMediaType)
-	layers = append(policyLayers, dataLayers...)
+	desc := ocispec.Descriptor{
+		MediaType: constants.OpenPolicyAgentConfigMediaType,
+		Digest:    digest.FromBytes(contents),
+		Size:      int64(len(contents)),
+	}
+	memoryStore.Set(desc, contents)
 
-	return layers, memoryStore
+	return desc
 }
 
-func buildLayer(ctx context.Context, paths []string, root string, memoryStore *content.Memorystore, mediaType string) []ocispec.Descriptor {
-	var layer ocispec.Descriptor
-	var layers []ocispec.Descriptor
-	for _, file := range paths {
-		contents, err := ioutil.ReadFile(file)
-		if err != nil {
-			log.G(ctx).Fatal(err)
+// bundleAnnotations returns the annotations of the bundle manifest: when it
+// was created, the conftest version, and the source and revision of the git
+// repository the bundle is pushed from. Annotations given as key=value
+// override these.
+func bundleAnnotations(root string, extra []string) (map[string]string, error) {
+	annotations := map[string]string{
+		ocispec.AnnotationCreated:           time.Now().UTC().Format(time.RFC3339),
+		constants.ConftestVersionAnnotation: constants.Version,
+	}
+
+	if source := gitOutput(root, "config", "--get", "remote.origin.url"); source != "" {
+		if u, err := url.Parse(source); err == nil && u.User != nil {
+			u.User = nil
+			source = u.String()
 		}
-		relative, err := filepath.Rel(root, file)
-		if err != nil {
-			log.G(ctx).Fatal(err)
+		annotations[ocispec.AnnotationSource] = source
+	}
+	if revision := gitOutput(root, "rev-parse", "HEAD"); revision != "" {
+		annotations[ocispec.AnnotationRevision] = revision
+	}
+
+	for _, annotation := range extra {
+		parts := strings.SplitN(annotation, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", annotation)
 		}
+		annotations[parts[0]] = parts[1]
+	}
 
-		path := filepath.ToSlash(relative)
+	return annotations, nil
+}
 
-		layer = memoryStore.Add(path, constants.OpenPolicyAgentPolicyLayerMediaType, contents)
-		layers = append(layers, layer)
+// gitOutput runs git in dir, returning an empty string when git is not
+// installed or dir is not in a git repository
+func gitOutput(dir string, args ...string) string {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return ""
 	}
-	return layers
+
+	return strings.TrimSpace(string(out))
 }
@@ -0,0 +1,147 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+	"github.com/instrumenta/conftest/pkg/policy"
+
+	"github.com/deislabs/oras/pkg/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestBuildLayers(t *testing.T) {
+	root, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"main.rego":                "package main",
+		"lib/kubernetes.rego":      "package lib.kubernetes",
+		"data.json":                "{}",
+		"exceptions/allowed.yaml":  "images: []",
+		"README.md":                "not part of the bundle",
+		"exceptions/allowed.json":  "{}",
+		"lib/kubernetes_test.rego": "package lib.kubernetes",
+		".shared/labels.json":      "{}",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	layers, memoryStore := buildLayers(context.Background(), root)
+
+	expected := map[string]string{
+		"main.rego":                constants.OpenPolicyAgentPolicyLayerMediaType,
+		"lib/kubernetes.rego":      constants.OpenPolicyAgentPolicyLayerMediaType,
+		"lib/kubernetes_test.rego": constants.OpenPolicyAgentPolicyLayerMediaType,
+		"data.json":                constants.OpenPolicyAgentDataLayerMediaType,
+		"exceptions/allowed.json":  constants.OpenPolicyAgentDataLayerMediaType,
+		"exceptions/allowed.yaml":  constants.OpenPolicyAgentYAMLDataLayerMediaType,
+		".shared/labels.json":      constants.OpenPolicyAgentDataLayerMediaType,
+		".manifest":                constants.OpenPolicyAgentManifestLayerMediaType,
+	}
+
+	actual := map[string]string{}
+	var manifestLayer ocispec.Descriptor
+	for _, layer := range layers {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		actual[name] = layer.MediaType
+		if name == policy.ManifestFileName {
+			manifestLayer = layer
+		}
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected layers %v, got %v", expected, actual)
+	}
+
+	_, contents, ok := memoryStore.Get(manifestLayer)
+	if !ok {
+		t.Fatal("the manifest layer should be in the memory store")
+	}
+
+	var manifest policy.Manifest
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		t.Fatalf("the manifest should be valid JSON: %v", err)
+	}
+
+	expectedRoots := []string{".shared", "exceptions", "lib/kubernetes", "main"}
+	if !reflect.DeepEqual(manifest.Roots, expectedRoots) {
+		t.Errorf("Expected roots %v, got %v", expectedRoots, manifest.Roots)
+	}
+}
+
+func TestBuildConfig(t *testing.T) {
+	memoryStore := content.NewMemoryStore()
+	layers := []ocispec.Descriptor{
+		memoryStore.Add("main.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte("package main\n\ndeny[msg] { msg = \"no\" }\nwarn[msg] { msg = \"maybe\" }\n")),
+		memoryStore.Add("deny.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte("package main\n\ndeny[msg] { msg = \"never\" }\n")),
+		memoryStore.Add("lib/kubernetes.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte("package lib.kubernetes\n\nis_deployment { input.kind = \"Deployment\" }\n")),
+		memoryStore.Add("data.json", constants.OpenPolicyAgentDataLayerMediaType, []byte("{}")),
+	}
+
+	desc := buildConfig(context.Background(), memoryStore, layers)
+	if desc.MediaType != constants.OpenPolicyAgentConfigMediaType {
+		t.Errorf("Expected %v, got %v", constants.OpenPolicyAgentConfigMediaType, desc.MediaType)
+	}
+
+	_, contents, ok := memoryStore.Get(desc)
+	if !ok {
+		t.Fatal("the config should be in the memory store")
+	}
+
+	var config policy.Config
+	if err := json.Unmarshal(contents, &config); err != nil {
+		t.Fatalf("the config should be valid JSON: %v", err)
+	}
+
+	expected := []policy.Namespace{
+		{Name: "lib.kubernetes", Rules: []string{"is_deployment"}},
+		{Name: "main", Rules: []string{"deny", "warn"}},
+	}
+	if !reflect.DeepEqual(config.Namespaces, expected) {
+		t.Errorf("Expected namespaces %v, got %v", expected, config.Namespaces)
+	}
+}
+
+func TestBundleAnnotations(t *testing.T) {
+	root, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	annotations, err := bundleAnnotations(root, []string{"org.opencontainers.image.version=1.2.0", "com.github.instrumenta.conftest.version=custom"})
+	if err != nil {
+		t.Fatalf("building annotations should not have thrown an error: %v", err)
+	}
+
+	if annotations[ocispec.AnnotationCreated] == "" {
+		t.Error("Expected the creation time to be annotated")
+	}
+	if annotations[ocispec.AnnotationVersion] != "1.2.0" {
+		t.Errorf("Expected %v, got %v", "1.2.0", annotations[ocispec.AnnotationVersion])
+	}
+	if annotations[constants.ConftestVersionAnnotation] != "custom" {
+		t.Errorf("Expected given annotations to override the defaults, got %v", annotations[constants.ConftestVersionAnnotation])
+	}
+
+	if _, err := bundleAnnotations(root, []string{"missing-value"}); err == nil {
+		t.Error("Expected an error for an annotation without a value")
+	}
+}
@@ -0,0 +1,146 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/instrumenta/conftest/pkg/commands/test"
+	"github.com/instrumenta/conftest/pkg/parser"
+
+	"github.com/containerd/containerd/log"
+	"github.com/open-policy-agent/opa/ast"
+	opaRepl "github.com/open-policy-agent/opa/repl"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const banner = `Conftest REPL. The policies are loaded and the configuration is available as input.
+Run 'help' to see a list of commands.`
+
+// NewREPLCommand creates a new repl command
+func NewREPLCommand() *cobra.Command {
+	ctx := context.Background()
+	cmd := &cobra.Command{
+		Use:   "repl <file> [file...]",
+		Short: "Start an interactive Rego REPL with your policies and configuration loaded",
+		Long:  `Start the Open Policy Agent REPL with the policies loaded and the parsed configuration available as input`,
+		Args:  cobra.MinimumNArgs(1),
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			test.BindParserFlags(cmd)
+		},
+
+		Run: func(cmd *cobra.Command, fileList []string) {
+			input, err := cmd.Flags().GetString("input")
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+			combine, err := cmd.Flags().GetBool(test.CombineConfigFlagName)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			policyPath := viper.GetString("policy")
+			compiler, err := test.BuildCompiler(policyPath)
+			if err != nil {
+				log.G(ctx).Fatalf("Problem building rego compiler: %s", err)
+			}
+
+			configurations, err := test.GetConfigurations(input, fileList)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			document, err := getInputDocument(configurations, combine)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+
+			store, err := buildStore(ctx, policyPath, compiler, document)
+			if err != nil {
+				log.G(ctx).Fatalf("Problem loading policies into the REPL: %s", err)
+			}
+
+			r := opaRepl.New(store, historyPath(), os.Stdout, "pretty", 0, banner)
+			r.Loop(ctx)
+		},
+	}
+
+	cmd.Flags().BoolP(test.CombineConfigFlagName, "", false, "combine all given config files into a single input document")
+	cmd.Flags().StringP("input", "i", "", fmt.Sprintf("input type for given source, especially useful when using conftest with stdin, valid options are: %s", parser.ValidInputs()))
+	test.AddParserFlags(cmd)
+
+	return cmd
+}
+
+// getInputDocument returns the document to bind to input. Multiple files can
+// only be loaded at once when they are combined into a single document.
+func getInputDocument(configurations map[string]interface{}, combine bool) (interface{}, error) {
+	if combine {
+		return configurations, nil
+	}
+
+	if len(configurations) != 1 {
+		return nil, fmt.Errorf("Loading %d files requires the --%s flag", len(configurations), test.CombineConfigFlagName)
+	}
+
+	for _, config := range configurations {
+		return config, nil
+	}
+
+	return nil, nil
+}
+
+// buildStore creates an in-memory store holding the source of the compiled
+// policy modules, read from policyPath. The REPL treats data.repl.input as the
+// input document, so the configuration is stored there.
+func buildStore(ctx context.Context, policyPath string, compiler *ast.Compiler, input interface{}) (storage.Store, error) {
+	dir := policyPath
+	if info, err := os.Stat(policyPath); err == nil && !info.IsDir() {
+		dir = filepath.Dir(policyPath)
+	}
+
+	store := inmem.NewFromObject(map[string]interface{}{
+		"repl": map[string]interface{}{
+			"input": input,
+		},
+	})
+
+	txn, err := store.NewTransaction(ctx, storage.WriteParams)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range compiler.Modules {
+		source, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			store.Abort(ctx, txn)
+			return nil, err
+		}
+
+		if err := store.UpsertPolicy(ctx, txn, name, source); err != nil {
+			store.Abort(ctx, txn)
+			return nil, err
+		}
+	}
+
+	if err := store.Commit(ctx, txn); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".conftest_history"
+	}
+
+	return filepath.Join(home, ".conftest_history")
+}
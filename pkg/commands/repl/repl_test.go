@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/commands/test"
+)
+
+func TestGetInputDocument(t *testing.T) {
+	deployment := map[string]interface{}{"kind": "Deployment"}
+	service := map[string]interface{}{"kind": "Service"}
+
+	tests := []struct {
+		name           string
+		configurations map[string]interface{}
+		combine        bool
+		expected       interface{}
+		shouldError    bool
+	}{
+		{
+			name:           "a single file is used as input",
+			configurations: map[string]interface{}{"deployment.yaml": deployment},
+			expected:       deployment,
+		},
+		{
+			name:           "multiple files are combined when requested",
+			configurations: map[string]interface{}{"deployment.yaml": deployment, "service.yaml": service},
+			combine:        true,
+			expected:       map[string]interface{}{"deployment.yaml": deployment, "service.yaml": service},
+		},
+		{
+			name:           "multiple files without combining is an error",
+			configurations: map[string]interface{}{"deployment.yaml": deployment, "service.yaml": service},
+			shouldError:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := getInputDocument(test.configurations, test.combine)
+			if test.shouldError && err == nil {
+				t.Fatal("we expected an error but did not get one")
+			}
+			if !test.shouldError && err != nil {
+				t.Fatalf("we did not expect an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBuildStore(t *testing.T) {
+	ctx := context.Background()
+	policyPath := "../../../examples/kubernetes/policy"
+
+	compiler, err := test.BuildCompiler(policyPath)
+	if err != nil {
+		t.Fatalf("could not build the compiler: %v", err)
+	}
+
+	store, err := buildStore(ctx, policyPath, compiler, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("could not build the store: %v", err)
+	}
+
+	txn, err := store.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Abort(ctx, txn)
+
+	stored, err := store.GetPolicy(ctx, txn, "deny.rego")
+	if err != nil {
+		t.Fatalf("the policy should have been stored: %v", err)
+	}
+
+	expected, err := ioutil.ReadFile(filepath.Join(policyPath, "deny.rego"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(stored) != string(expected) {
+		t.Errorf("Expected the policy to be stored as written, got %s", stored)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/spf13/viper"
+)
+
+const (
+	coverageTable = "table"
+	coverageJSON  = "json"
+)
+
+func validCoverageFormats() []string {
+	return []string{
+		coverageTable,
+		coverageJSON,
+	}
+}
+
+// reportCoverage writes the coverage report to the coverage file, or to
+// stderr so it does not interfere with the results written to stdout.
+func reportCoverage(report cover.Report) error {
+	var w io.Writer = os.Stderr
+	if path := viper.GetString("coverage-file"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return writeCoverage(w, viper.GetString("coverage-format"), report)
+}
+
+func writeCoverage(w io.Writer, format string, report cover.Report) error {
+	switch format {
+	case coverageJSON:
+		b, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case coverageTable, "":
+		return writeCoverageTable(w, report)
+	default:
+		return fmt.Errorf("unknown coverage format given: %v", format)
+	}
+}
+
+func writeCoverageTable(w io.Writer, report cover.Report) error {
+	var modules []string
+	for module := range report.Files {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tCOVERAGE")
+	for _, module := range modules {
+		fmt.Fprintf(tw, "%s\t%.2f%%\n", module, report.Files[module].Coverage)
+	}
+	fmt.Fprintf(tw, "TOTAL\t%.2f%%\n", report.Coverage)
+
+	return tw.Flush()
+}
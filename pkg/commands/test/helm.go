@@ -0,0 +1,36 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/helm"
+	"github.com/instrumenta/conftest/pkg/parser"
+)
+
+// GetChartConfigurations renders the Helm chart at chartPath with the given
+// values files and parses the manifests as YAML. The parsed documents are
+// keyed by the template that produced them.
+func GetChartConfigurations(chartPath string, valueFiles []string) (map[string]interface{}, error) {
+	rendered, err := helm.Render(chartPath, valueFiles)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to render Helm chart: %v", err)
+	}
+
+	var configFiles []parser.ConfigDoc
+	for name, manifest := range rendered {
+		configFiles = append(configFiles, parser.ConfigDoc{
+			ReadCloser: ioutil.NopCloser(strings.NewReader(manifest)),
+			Filepath:   name,
+		})
+	}
+
+	configManager := parser.NewConfigManager("yaml")
+	configurations, err := configManager.BulkUnmarshal(configFiles)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse rendered Helm chart: %v", err)
+	}
+
+	return configurations, nil
+}
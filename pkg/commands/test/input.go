@@ -0,0 +1,65 @@
+package test
+
+import (
+	"os"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/parser"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// parserFlagNames are the flags configuring how input files are parsed.
+// They are read by ParserOptions.
+var parserFlagNames = []string{"dockerfile-stages", "nest-properties", "ini-infer-types", "ini-comments", "jsonnet-jpath", "jsonnet-ext-var", "compose-override", "compose-env"}
+
+// AddParserFlags adds the flags configuring the parsers to a command that
+// reads configuration files
+func AddParserFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("dockerfile-stages", "", false, "group the instructions of Dockerfiles into build stages with their variables resolved")
+	cmd.Flags().BoolP("nest-properties", "", false, "nest dotted keys of .properties files into objects")
+	cmd.Flags().BoolP("ini-infer-types", "", false, "convert numbers and booleans in INI files from strings, true and false match in any case while yes and on stay strings")
+	cmd.Flags().BoolP("ini-comments", "", false, "keep the comments of INI sections and keys under the __comments__ key of each section")
+	cmd.Flags().StringSliceP("jsonnet-jpath", "", []string{}, "library directory searched for Jsonnet imports")
+	cmd.Flags().StringSliceP("jsonnet-ext-var", "", []string{}, "Jsonnet external variable as name=value, or name to read it from the environment")
+	cmd.Flags().StringSliceP("compose-override", "", []string{}, "override file merged into Docker Compose files read with --input compose, can be given more than once")
+	cmd.Flags().StringSliceP("compose-env", "", []string{}, "variable used to interpolate Docker Compose files as name=value, taking precedence over the environment and .env file")
+}
+
+// BindParserFlags binds the parser flags of a command to the configuration
+func BindParserFlags(cmd *cobra.Command) {
+	for _, name := range parserFlagNames {
+		viper.BindPFlag(name, cmd.Flags().Lookup(name))
+	}
+}
+
+// ParserOptions returns the parser options given by the parser flags. Docker
+// Compose files are interpolated from the environment, followed by the
+// --compose-env flags so that they take precedence.
+func ParserOptions() parser.Options {
+	return parser.Options{
+		DockerfileStages: viper.GetBool("dockerfile-stages"),
+		NestProperties:   viper.GetBool("nest-properties"),
+		INIInferTypes:    viper.GetBool("ini-infer-types"),
+		INIComments:      viper.GetBool("ini-comments"),
+		JsonnetJPaths:    viper.GetStringSlice("jsonnet-jpath"),
+		JsonnetExtVars:   viper.GetStringSlice("jsonnet-ext-var"),
+		ComposeOverrides: viper.GetStringSlice("compose-override"),
+		ComposeEnv:       append(environment(), viper.GetStringSlice("compose-env")...),
+	}
+}
+
+// environment returns the variables of the process environment as
+// name=value. Entries without a name, such as the =C:=C:\ entries Windows
+// keeps for the working directory of each drive, are skipped.
+func environment() []string {
+	var variables []string
+	for _, variable := range os.Environ() {
+		if strings.Index(variable, "=") > 0 {
+			variables = append(variables, variable)
+		}
+	}
+
+	return variables
+}
@@ -97,9 +97,15 @@ func (s *stdOutputManager) Flush() error {
 }
 
 type jsonCheckResult struct {
-	Filename string   `json:"filename"`
-	Warnings []string `json:"Warnings"`
-	Failures []string `json:"Failures"`
+	Filename string      `json:"filename"`
+	Warnings []string    `json:"Warnings"`
+	Failures []string    `json:"Failures"`
+	Traces   []jsonTrace `json:"Traces,omitempty"`
+}
+
+type jsonTrace struct {
+	Query string   `json:"query"`
+	Trace []string `json:"trace"`
 }
 
 // jsonOutputManager reports `conftest` results to `stdout` as a json array..
@@ -130,6 +136,18 @@ func errsToStrings(errs []error) []string {
 	return res
 }
 
+func tracesToJSON(traces []Trace) []jsonTrace {
+	var res []jsonTrace
+	for _, trace := range traces {
+		res = append(res, jsonTrace{
+			Query: trace.Query,
+			Trace: trace.Lines,
+		})
+	}
+
+	return res
+}
+
 func (j *jsonOutputManager) Put(fileName string, cr CheckResult) error {
 
 	if fileName == "-" {
@@ -140,6 +158,7 @@ func (j *jsonOutputManager) Put(fileName string, cr CheckResult) error {
 		Filename: fileName,
 		Warnings: errsToStrings(cr.Warnings),
 		Failures: errsToStrings(cr.Failures),
+		Traces:   tracesToJSON(cr.Traces),
 	})
 
 	return nil
@@ -131,6 +131,33 @@ func Test_jsonOutputManager_put(t *testing.T) {
 		]
 	}
 ]
+`,
+		},
+		{
+			msg: "embeds traces",
+			args: args{
+				fileName: "examples/kubernetes/service.yaml",
+				cr: test.CheckResult{
+					Traces: []test.Trace{
+						{Query: "data.main.deny", Lines: []string{"Enter data.main.deny = _"}},
+					},
+				},
+			},
+			exp: `[
+	{
+		"filename": "examples/kubernetes/service.yaml",
+		"Warnings": [],
+		"Failures": [],
+		"Traces": [
+			{
+				"query": "data.main.deny",
+				"trace": [
+					"Enter data.main.deny = _"
+				]
+			}
+		]
+	}
+]
 `,
 		},
 		{
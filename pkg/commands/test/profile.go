@@ -0,0 +1,174 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/open-policy-agent/opa/profiler"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/spf13/viper"
+)
+
+// queryRecorder is implemented by tracers that also want to know how long
+// each rule query took to evaluate. startQuery is called right before the
+// evaluation and recordQuery right after it, so the time excludes compiling
+// the query.
+type queryRecorder interface {
+	startQuery()
+	recordQuery(query string, elapsed time.Duration)
+}
+
+type queryStats struct {
+	Query   string
+	Elapsed time.Duration
+	Calls   int
+}
+
+// queryProfiler collects the time spent in each rule query, alongside the
+// expression level statistics gathered by the OPA profiler. The OPA profiler
+// charges the last expression it saw with all the time until the next event,
+// so a new one is used for each evaluation and its results are merged once
+// the evaluation ends.
+type queryProfiler struct {
+	current *profiler.Profiler
+	hits    map[string]map[int]profiler.ExprStats
+	queries map[string]*queryStats
+}
+
+func newQueryProfiler() *queryProfiler {
+	return &queryProfiler{
+		hits:    map[string]map[int]profiler.ExprStats{},
+		queries: map[string]*queryStats{},
+	}
+}
+
+// Enabled returns true as the profiler always traces
+func (p *queryProfiler) Enabled() bool {
+	return true
+}
+
+// Trace passes the event to the profiler of the running evaluation
+func (p *queryProfiler) Trace(event *topdown.Event) {
+	if p.current != nil {
+		p.current.Trace(event)
+	}
+}
+
+func (p *queryProfiler) startQuery() {
+	p.current = profiler.New()
+}
+
+func (p *queryProfiler) recordQuery(query string, elapsed time.Duration) {
+	stats, ok := p.queries[query]
+	if !ok {
+		stats = &queryStats{Query: query}
+		p.queries[query] = stats
+	}
+
+	stats.Elapsed += elapsed
+	stats.Calls++
+
+	if p.current == nil {
+		return
+	}
+	for file, report := range p.current.ReportByFile().Files {
+		hits, ok := p.hits[file]
+		if !ok {
+			hits = map[int]profiler.ExprStats{}
+			p.hits[file] = hits
+		}
+		for _, stat := range report.Result {
+			if total, ok := hits[stat.Location.Row]; ok {
+				stat.ExprTimeNs += total.ExprTimeNs
+				stat.NumEval += total.NumEval
+				stat.NumRedo += total.NumRedo
+			}
+			hits[stat.Location.Row] = stat
+		}
+	}
+	p.current = nil
+}
+
+// topExpressions returns the n expressions with the largest total evaluation
+// time across every query.
+func (p *queryProfiler) topExpressions(n int) []profiler.ExprStats {
+	var res []profiler.ExprStats
+	for _, hits := range p.hits {
+		for _, stat := range hits {
+			res = append(res, stat)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].ExprTimeNs != res[j].ExprTimeNs {
+			return res[i].ExprTimeNs > res[j].ExprTimeNs
+		}
+		if res[i].Location.File != res[j].Location.File {
+			return res[i].Location.File < res[j].Location.File
+		}
+		return res[i].Location.Row < res[j].Location.Row
+	})
+
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+
+	return res
+}
+
+// topQueries returns the n rule queries with the largest total evaluation time.
+func (p *queryProfiler) topQueries(n int) []queryStats {
+	var res []queryStats
+	for _, stats := range p.queries {
+		res = append(res, *stats)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Elapsed == res[j].Elapsed {
+			return res[i].Query < res[j].Query
+		}
+		return res[i].Elapsed > res[j].Elapsed
+	})
+
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+
+	return res
+}
+
+// reportProfile writes the top rule and expression hotspots to stderr.
+func reportProfile(p *queryProfiler) error {
+	return writeProfile(os.Stderr, p, viper.GetInt("profile-limit"))
+}
+
+func writeProfile(w io.Writer, p *queryProfiler, limit int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tTIME\tCALLS")
+	for _, stats := range p.topQueries(limit) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", stats.Query, stats.Elapsed, stats.Calls)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCATION\tTIME\tEVALS\tREDOS\tEXPRESSION")
+	for _, stats := range p.topExpressions(limit) {
+		var location, text string
+		if stats.Location != nil {
+			location = fmt.Sprintf("%s:%d", stats.Location.File, stats.Location.Row)
+			text = strings.TrimSpace(string(stats.Location.Text))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", location, time.Duration(stats.ExprTimeNs), stats.NumEval, stats.NumRedo, text)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+)
+
+func TestTopQueries(t *testing.T) {
+	profile := newQueryProfiler()
+	profile.recordQuery("data.main.deny", 3*time.Millisecond)
+	profile.recordQuery("data.main.warn", 5*time.Millisecond)
+	profile.recordQuery("data.main.deny", 4*time.Millisecond)
+	profile.recordQuery("data.main.violation", 2*time.Millisecond)
+	profile.recordQuery("data.main.deny_labels", 2*time.Millisecond)
+
+	tests := []struct {
+		name     string
+		limit    int
+		expected []queryStats
+	}{
+		{
+			name:  "all queries are returned without a limit",
+			limit: 0,
+			expected: []queryStats{
+				{Query: "data.main.deny", Elapsed: 7 * time.Millisecond, Calls: 2},
+				{Query: "data.main.warn", Elapsed: 5 * time.Millisecond, Calls: 1},
+				{Query: "data.main.deny_labels", Elapsed: 2 * time.Millisecond, Calls: 1},
+				{Query: "data.main.violation", Elapsed: 2 * time.Millisecond, Calls: 1},
+			},
+		},
+		{
+			name:  "the slowest queries are returned up to the limit",
+			limit: 2,
+			expected: []queryStats{
+				{Query: "data.main.deny", Elapsed: 7 * time.Millisecond, Calls: 2},
+				{Query: "data.main.warn", Elapsed: 5 * time.Millisecond, Calls: 1},
+			},
+		},
+		{
+			name:  "queries with the same time are ordered by name",
+			limit: 3,
+			expected: []queryStats{
+				{Query: "data.main.deny", Elapsed: 7 * time.Millisecond, Calls: 2},
+				{Query: "data.main.warn", Elapsed: 5 * time.Millisecond, Calls: 1},
+				{Query: "data.main.deny_labels", Elapsed: 2 * time.Millisecond, Calls: 1},
+			},
+		},
+		{
+			name:  "a limit larger than the number of queries returns them all",
+			limit: 10,
+			expected: []queryStats{
+				{Query: "data.main.deny", Elapsed: 7 * time.Millisecond, Calls: 2},
+				{Query: "data.main.warn", Elapsed: 5 * time.Millisecond, Calls: 1},
+				{Query: "data.main.deny_labels", Elapsed: 2 * time.Millisecond, Calls: 1},
+				{Query: "data.main.violation", Elapsed: 2 * time.Millisecond, Calls: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := profile.topQueries(test.limit)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteProfile(t *testing.T) {
+	ctx := context.Background()
+	compiler, err := BuildCompiler("testdata/policy/test_policy.rego")
+	if err != nil {
+		t.Fatalf("could not build the compiler: %v", err)
+	}
+
+	profile := newQueryProfiler()
+	query := "data.main.deny_wrongname"
+	input := map[string]interface{}{"metadata": map[string]interface{}{"name": "hello-kubernetes"}}
+	if _, _, err := runQuery(ctx, query, input, compiler, false, profile); err != nil {
+		t.Fatalf("could not evaluate the query: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		limit       int
+		rows        int
+		expressions []string
+	}{
+		{
+			name:        "every expression is written up to the limit",
+			limit:       10,
+			rows:        3,
+			expressions: []string{query, `input.metadata.name == "hello-kubernetes"`, `sprintf("nothing to see here %v", [input])`},
+		},
+		{
+			name:  "the expressions are limited",
+			limit: 1,
+			rows:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeProfile(&buf, profile, test.limit); err != nil {
+				t.Fatalf("could not write the profile: %v", err)
+			}
+
+			var rows [][]string
+			for _, line := range strings.Split(buf.String(), "\n") {
+				if strings.TrimSpace(line) != "" {
+					rows = append(rows, strings.Fields(line))
+				}
+			}
+
+			if len(rows) != 3+test.rows {
+				t.Fatalf("Expected %d rows, got %d: %v", 3+test.rows, len(rows), rows)
+			}
+
+			ruleHeader := []string{"RULE", "TIME", "CALLS"}
+			expressionHeader := []string{"LOCATION", "TIME", "EVALS", "REDOS", "EXPRESSION"}
+			if !reflect.DeepEqual(rows[0], ruleHeader) || !reflect.DeepEqual(rows[2], expressionHeader) {
+				t.Errorf("Expected the headers %v and %v, got %v and %v", ruleHeader, expressionHeader, rows[0], rows[2])
+			}
+			if len(rows[1]) != 3 || rows[1][0] != query || rows[1][2] != "1" {
+				t.Errorf("Expected the rule %s called once, got %v", query, rows[1])
+			}
+
+			for _, expression := range test.expressions {
+				found := false
+				for _, row := range rows[3:] {
+					if len(row) >= 5 && strings.Join(row[4:], " ") == expression {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("Expected a row for the expression %s, got %v", expression, rows[3:])
+				}
+			}
+		})
+	}
+}
+
+func TestRunQueryRecordsEvaluationTime(t *testing.T) {
+	ctx := context.Background()
+	compiler := ast.MustCompileModules(map[string]string{
+		"test.rego": `package main
+
+deny[msg] {
+	input.kind == "Deployment"
+	msg = "no deployments"
+}`,
+	})
+
+	profile := newQueryProfiler()
+	for i := 0; i < 3; i++ {
+		if _, _, err := runQuery(ctx, "data.main.deny", map[string]interface{}{"kind": "Deployment"}, compiler, false, profile); err != nil {
+			t.Fatalf("could not evaluate the query: %v", err)
+		}
+	}
+
+	stats := profile.topQueries(0)
+	if len(stats) != 1 || stats[0].Calls != 3 {
+		t.Fatalf("Expected one query called 3 times, got %v", stats)
+	}
+	if stats[0].Elapsed <= 0 {
+		t.Errorf("Expected the evaluation time to be recorded, got %v", stats[0].Elapsed)
+	}
+}
+
+func TestProfileExcludesTimeBetweenQueries(t *testing.T) {
+	ctx := context.Background()
+	compiler := ast.MustCompileModules(map[string]string{
+		"test.rego": `package main
+
+deny[msg] {
+	input.kind == "Deployment"
+	msg = "no deployments"
+}`,
+	})
+
+	profile := newQueryProfiler()
+	pause := 50 * time.Millisecond
+	for i := 0; i < 2; i++ {
+		if _, _, err := runQuery(ctx, "data.main.deny", map[string]interface{}{"kind": "Deployment"}, compiler, false, profile); err != nil {
+			t.Fatalf("could not evaluate the query: %v", err)
+		}
+		time.Sleep(pause)
+	}
+
+	stats := profile.topExpressions(0)
+	if len(stats) == 0 {
+		t.Fatal("Expected the expressions to be profiled")
+	}
+	for _, stat := range stats {
+		if stat.NumEval != 2 {
+			t.Errorf("Expected %s:%d to be evaluated twice, got %d", stat.Location.File, stat.Location.Row, stat.NumEval)
+		}
+		if time.Duration(stat.ExprTimeNs) >= pause {
+			t.Errorf("Expected the time between queries to be excluded from %s:%d, got %v", stat.Location.File, stat.Location.Row, time.Duration(stat.ExprTimeNs))
+		}
+	}
+}
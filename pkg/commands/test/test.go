@@ -11,13 +11,16 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/instrumenta/conftest/pkg/commands/update"
 	"github.com/instrumenta/conftest/pkg/constants"
+	"github.com/instrumenta/conftest/pkg/kustomize"
 	"github.com/instrumenta/conftest/pkg/parser"
 
 	"github.com/containerd/containerd/log"
 	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/open-policy-agent/opa/topdown"
 	"github.com/spf13/cobra"
@@ -36,6 +39,7 @@ var (
 type CheckResult struct {
 	Warnings []error
 	Failures []error
+	Traces   []Trace
 }
 
 // NewTestCommand creates a new test command
@@ -49,7 +53,8 @@ func NewTestCommand(osExit func(int), getOutputManager func() OutputManager) *co
 
 		Run: func(cmd *cobra.Command, fileList []string) {
 			out := getOutputManager()
-			if len(fileList) < 1 {
+			chart := viper.GetString("helm-chart")
+			if len(fileList) < 1 && chart == "" {
 				cmd.SilenceErrors = true
 				log.G(ctx).Fatal("The first argument should be a file")
 			}
@@ -58,69 +63,105 @@ func NewTestCommand(osExit func(int), getOutputManager func() OutputManager) *co
 				update.NewUpdateCommand().Run(cmd, fileList)
 			}
 
-			compiler, err := buildCompiler(viper.GetString("policy"))
+			compiler, err := BuildCompiler(viper.GetString("policy"))
 			if err != nil {
 				log.G(ctx).Fatalf("Problem building rego compiler: %s", err)
 			}
+			traceOut, err := getTraceWriter()
+			if err != nil {
+				log.G(ctx).Fatalf("Problem opening trace file: %s", err)
+			}
+			defer traceOut.Close()
+
+			var tracers []topdown.Tracer
+			var coverage *cover.Cover
+			if viper.GetBool("coverage") {
+				coverage = cover.New()
+				tracers = append(tracers, coverage)
+			}
+			var profile *queryProfiler
+			if viper.GetBool("profile") {
+				profile = newQueryProfiler()
+				tracers = append(tracers, profile)
+			}
+
 			foundFailures := false
-			var configFiles []parser.ConfigDoc
-			var fileType string
-			for _, fileName := range fileList {
-				var err error
-				var config io.ReadCloser
-				fileType, err = getFileType(viper.GetString("input"), fileName)
+			configurations := map[string]interface{}{}
+			if len(fileList) > 0 {
+				configurations, err = GetConfigurations(viper.GetString("input"), fileList)
 				if err != nil {
-					log.G(ctx).Printf("Unable to get file type: %v", err)
+					log.G(ctx).Print(err)
 					osExit(1)
+					return
 				}
-				config, err = getConfig(fileName)
+			}
+			if chart != "" {
+				rendered, err := GetChartConfigurations(chart, viper.GetStringSlice("helm-values"))
 				if err != nil {
-					log.G(ctx).Printf("Unable to open file or read from stdin %s", err)
+					log.G(ctx).Print(err)
 					osExit(1)
+					return
+				}
+				for name, config := range rendered {
+					configurations[name] = config
 				}
-				configFiles = append(configFiles, parser.ConfigDoc{
-					ReadCloser: config,
-					Filepath:   fileName,
-				})
-			}
-			configManager := parser.NewConfigManager(fileType)
-			configurations, err := configManager.BulkUnmarshal(configFiles)
-			if err != nil {
-				log.G(ctx).Printf("Unable to BulkUnmarshal your config files: %v", err)
-				osExit(1)
 			}
 
-			var res CheckResult
 			if viper.GetBool(CombineConfigFlagName) {
-				res, err = processData(ctx, configurations, compiler)
+				res, err := processData(ctx, configurations, compiler, tracers...)
 				if err != nil {
 					log.G(ctx).Fatalf("Problem processing data: %s", err)
 				}
+				err = writeTraces(traceOut, "Combined-configs (multi-file)", res.Traces)
+				if err != nil {
+					log.G(ctx).Fatalf("Problem writing trace: %s", err)
+				}
 				err = out.Put("Combined-configs (multi-file)", res)
 				if err != nil {
 					log.G(ctx).Fatalf("Problem generating output: %s", err)
 				}
+				if isFailure(res) {
+					foundFailures = true
+				}
 			} else {
 				for fileName, config := range configurations {
-					res, err = processData(ctx, config, compiler)
+					res, err := processData(ctx, config, compiler, tracers...)
 					if err != nil {
 						log.G(ctx).Fatalf("Problem processing data: %s", err)
 					}
+					err = writeTraces(traceOut, fileName, res.Traces)
+					if err != nil {
+						log.G(ctx).Fatalf("Problem writing trace: %s", err)
+					}
 					err = out.Put(fileName, res)
 					if err != nil {
 						log.G(ctx).Fatalf("Problem generating output: %s", err)
 					}
+					if isFailure(res) {
+						foundFailures = true
+					}
 				}
 			}
-			if len(res.Failures) > 0 || (len(res.Warnings) > 0 && viper.GetBool("fail-on-warn")) {
-				foundFailures = true
-			}
 
 			err = out.Flush()
 			if err != nil {
 				log.G(ctx).Fatal(err)
 			}
 
+			if coverage != nil {
+				err = reportCoverage(coverage.Report(compiler.Modules))
+				if err != nil {
+					log.G(ctx).Fatalf("Problem reporting coverage: %s", err)
+				}
+			}
+
+			if profile != nil {
+				err = reportProfile(profile)
+				if err != nil {
+					log.G(ctx).Fatalf("Problem reporting profile: %s", err)
+				}
+			}
+
 			if foundFailures {
 				osExit(1)
 			}
@@ -129,13 +170,28 @@ func NewTestCommand(osExit func(int), getOutputManager func() OutputManager) *co
 
 	cmd.Flags().BoolP("fail-on-warn", "", false, "return a non-zero exit code if only warnings are found")
 	cmd.Flags().BoolP("update", "", false, "update any policies before running the tests")
+	cmd.Flags().BoolP("offline", "", false, "with --update, use cached policies without contacting their source")
+	cmd.Flags().DurationP("cache-ttl", "", 0, "with --update, reuse cached policies downloaded within this duration")
+	cmd.Flags().BoolP("locked", "", false, "when updating, download exactly the policy digests recorded in the lock file")
+	cmd.Flags().StringSliceP("verify-key", "", []string{}, "with --update, path to a PEM encoded public key used to verify policy signatures")
 	cmd.Flags().BoolP(CombineConfigFlagName, "", false, "combine all given config files to be evaluated together")
 
 	cmd.Flags().StringP("output", "o", "", fmt.Sprintf("output format for conftest results - valid options are: %s", validOutputs()))
 	cmd.Flags().StringP("input", "i", "", fmt.Sprintf("input type for given source, especially useful when using conftest with stdin, valid options are: %s", parser.ValidInputs()))
+	AddParserFlags(cmd)
+	cmd.Flags().StringP("helm-chart", "", "", "render the Helm chart in the given directory or archive and test the resulting manifests")
+	cmd.Flags().StringSliceP("helm-values", "f", []string{}, "values file used to render --helm-chart, can be given more than once")
+	cmd.Flags().StringP("trace-file", "", "", "write trace output to the given file instead of stderr")
+	cmd.Flags().StringSliceP("trace-rule", "", []string{}, "only trace the given rules, for example deny_root")
+	cmd.Flags().BoolP("coverage", "", false, "report which lines of the policies were evaluated")
+	cmd.Flags().StringP("coverage-format", "", coverageTable, fmt.Sprintf("format of the coverage report - valid options are: %s", validCoverageFormats()))
+	cmd.Flags().StringP("coverage-file", "", "", "write the coverage report to the given file instead of stderr")
+	cmd.Flags().BoolP("profile", "", false, "report the time spent evaluating each rule and expression")
+	cmd.Flags().IntP("profile-limit", "", 10, "number of hotspots to include in the profile report")
 
 	var err error
-	flagNames := []string{"fail-on-warn", "update", CombineConfigFlagName, "output", "input"}
+	flagNames := []string{"fail-on-warn", "update", "offline", "cache-ttl", "locked", "verify-key", CombineConfigFlagName, "output", "input", "helm-chart", "helm-values", "trace-file", "trace-rule", "coverage", "coverage-format", "coverage-file", "profile", "profile-limit"}
+	flagNames = append(flagNames, parserFlagNames...)
 	for _, name := range flagNames {
 		err = viper.BindPFlag(name, cmd.Flags().Lookup(name))
 		if err != nil {
@@ -146,6 +202,64 @@ func NewTestCommand(osExit func(int), getOutputManager func() OutputManager) *co
 	return cmd
 }
 
+// GetConfigurations reads and parses the given files, returning the parsed
+// documents keyed by file name. If input is empty the parser is chosen from
+// the file name. Directories holding a kustomization are built, and each
+// resulting resource is keyed by the file declaring it, its kind, namespace
+// and name.
+func GetConfigurations(input string, fileList []string) (map[string]interface{}, error) {
+	var configFiles []parser.ConfigDoc
+	var kustomizations []string
+	var fileType string
+	for _, fileName := range fileList {
+		if kustomize.IsKustomization(fileName) {
+			kustomizations = append(kustomizations, fileName)
+			continue
+		}
+
+		var err error
+		var config io.ReadCloser
+		fileType, err = getFileType(input, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get file type: %v", err)
+		}
+		config, err = getConfig(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open file or read from stdin %s", err)
+		}
+		configFiles = append(configFiles, parser.ConfigDoc{
+			ReadCloser: config,
+			Filepath:   fileName,
+		})
+	}
+
+	configurations := map[string]interface{}{}
+	if len(configFiles) > 0 {
+		var err error
+		configManager := parser.NewConfigManagerWithOptions(fileType, ParserOptions())
+		configurations, err = configManager.BulkUnmarshal(configFiles)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to BulkUnmarshal your config files: %v", err)
+		}
+	}
+
+	for _, dir := range kustomizations {
+		resources, err := kustomize.Build(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to build kustomization %s: %v", dir, err)
+		}
+		for _, resource := range resources {
+			name := resource.Name
+			if resource.Namespace != "" {
+				name = resource.Namespace + "/" + name
+			}
+			configurations[fmt.Sprintf("%s (%s/%s)", resource.Origin, resource.Kind, name)] = resource.Object
+		}
+	}
+
+	return configurations, nil
+}
+
 func getConfig(fileName string) (io.ReadCloser, error) {
 	if fileName == "-" {
 		config := ioutil.NopCloser(bufio.NewReader(os.Stdin))
@@ -160,7 +274,12 @@ func getConfig(fileName string) (io.ReadCloser, error) {
 	return config, nil
 }
 
-func buildRego(trace bool, query string, input interface{}, compiler *ast.Compiler) (*rego.Rego, *topdown.BufferTracer) {
+// isFailure reports whether the result should give a non-zero exit code
+func isFailure(res CheckResult) bool {
+	return len(res.Failures) > 0 || (len(res.Warnings) > 0 && viper.GetBool("fail-on-warn"))
+}
+
+func buildRego(trace bool, query string, input interface{}, compiler *ast.Compiler, tracers ...topdown.Tracer) (*rego.Rego, *topdown.BufferTracer) {
 	var regoObj *rego.Rego
 	var regoFunc []func(r *rego.Rego)
 	buf := topdown.NewBufferTracer()
@@ -169,6 +288,9 @@ func buildRego(trace bool, query string, input interface{}, compiler *ast.Compil
 	if trace {
 		regoFunc = append(regoFunc, rego.Tracer(buf))
 	}
+	for _, tracer := range tracers {
+		regoFunc = append(regoFunc, rego.Tracer(tracer))
+	}
 	regoObj = rego.New(regoFunc...)
 
 	return regoObj, buf
@@ -180,7 +302,9 @@ func getFileType(inputFileType, fileName string) (string, error) {
 	}
 	if fileName != "-" {
 		fileType := ""
-		if strings.Contains(fileName, ".") {
+		if base := filepath.Base(fileName); base == ".env" || strings.HasPrefix(base, ".env.") {
+			fileType = "env"
+		} else if strings.Contains(fileName, ".") {
 			fileType = strings.TrimPrefix(filepath.Ext(fileName), ".")
 		} else {
 			ss := strings.SplitAfter(fileName, "/")
@@ -228,35 +352,42 @@ func makeQuery(rule string) string {
 	return fmt.Sprintf("data.%s.%s", viper.GetString("namespace"), rule)
 }
 
-func processData(ctx context.Context, input interface{}, compiler *ast.Compiler) (CheckResult, error) {
+// processData evaluates the warn and deny rules against the given input. Any
+// additional tracers, such as the coverage tracer, observe every query.
+func processData(ctx context.Context, input interface{}, compiler *ast.Compiler, tracers ...topdown.Tracer) (CheckResult, error) {
+	var traces []Trace
+
 	// collect warnings
 	var warnings []error
 	for _, rule := range getRules(ctx, WarnQ, compiler) {
-		warns, err := runQuery(ctx, makeQuery(rule), input, compiler)
+		warns, trace, err := runQuery(ctx, makeQuery(rule), input, compiler, shouldTrace(rule), tracers...)
 		if err != nil {
 			return CheckResult{}, err
 		}
 
 		warnings = append(warnings, warns...)
+		traces = append(traces, trace...)
 	}
 
 	// collect failures
 	var failures []error
 	for _, r := range getRules(ctx, DenyQ, compiler) {
-		fails, err := runQuery(ctx, makeQuery(r), input, compiler)
+		fails, trace, err := runQuery(ctx, makeQuery(r), input, compiler, shouldTrace(r), tracers...)
 		if err != nil {
 			return CheckResult{}, err
 		}
 		failures = append(failures, fails...)
+		traces = append(traces, trace...)
 	}
 
 	return CheckResult{
 		Failures: failures,
 		Warnings: warnings,
+		Traces:   traces,
 	}, nil
 }
 
-func runQuery(ctx context.Context, query string, input interface{}, compiler *ast.Compiler) ([]error, error) {
+func runQuery(ctx context.Context, query string, input interface{}, compiler *ast.Compiler, trace bool, tracers ...topdown.Tracer) ([]error, []Trace, error) {
 	hasResults := func(expression interface{}) bool {
 		if v, ok := expression.([]interface{}); ok {
 			return len(v) > 0
@@ -264,14 +395,36 @@ func runQuery(ctx context.Context, query string, input interface{}, compiler *as
 		return false
 	}
 
-	r, stdout := buildRego(viper.GetBool("trace"), query, input, compiler)
-	rs, err := r.Eval(ctx)
+	r, stdout := buildRego(trace, query, input, compiler, tracers...)
+	prepared, err := r.PrepareForEval(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Problem evaluating r policy: %s", err)
+	}
+
+	// only the evaluation is timed, the query is compiled beforehand
+	for _, tracer := range tracers {
+		if recorder, ok := tracer.(queryRecorder); ok {
+			recorder.startQuery()
+		}
+	}
+	start := time.Now()
+	rs, err := prepared.Eval(ctx)
+	elapsed := time.Since(start)
 
 	if err != nil {
-		return nil, fmt.Errorf("Problem evaluating r policy: %s", err)
+		return nil, nil, fmt.Errorf("Problem evaluating r policy: %s", err)
 	}
 
-	topdown.PrettyTrace(os.Stdout, *stdout)
+	for _, tracer := range tracers {
+		if recorder, ok := tracer.(queryRecorder); ok {
+			recorder.recordQuery(query, elapsed)
+		}
+	}
+
+	var traces []Trace
+	if trace {
+		traces = append(traces, newTrace(query, *stdout))
+	}
 
 	var errs []error
 
@@ -286,44 +439,61 @@ func runQuery(ctx context.Context, query string, input interface{}, compiler *as
 		}
 	}
 
-	return errs, nil
+	return errs, traces, nil
 }
 
-func buildCompiler(path string) (*ast.Compiler, error) {
+// BuildCompiler parses and compiles the Rego policies found at the given path,
+// which may be either a directory or a single .rego file. Directories are
+// searched recursively, so policies pulled into subdirectories are found.
+func BuildCompiler(path string) (*ast.Compiler, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
-	var files []os.FileInfo
+	var files []string
 	var dirPath string
 	if info.IsDir() {
-		files, err = ioutil.ReadDir(path)
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".rego") {
+				files = append(files, file)
+			}
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
 		dirPath = path
 	} else {
-		files = []os.FileInfo{info}
+		files = []string{path}
 		dirPath = filepath.Dir(path)
 	}
 
 	modules := map[string]*ast.Module{}
 
 	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".rego") {
+		if !strings.HasSuffix(file, ".rego") {
 			continue
 		}
 
-		out, err := ioutil.ReadFile(dirPath + "/" + file.Name())
+		name, err := filepath.Rel(dirPath, file)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(name)
+
+		out, err := ioutil.ReadFile(file)
 		if err != nil {
 			return nil, err
 		}
 
-		parsed, err := ast.ParseModule(file.Name(), string(out[:]))
+		parsed, err := ast.ParseModule(name, string(out[:]))
 		if err != nil {
 			return nil, err
 		}
-		modules[file.Name()] = parsed
+		modules[name] = parsed
 	}
 
 	compiler := ast.NewCompiler()
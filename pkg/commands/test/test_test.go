@@ -1,10 +1,16 @@
 package test_test
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/instrumenta/conftest/pkg/commands/test"
 	"github.com/instrumenta/conftest/pkg/commands/test/testfakes"
+	"github.com/instrumenta/conftest/pkg/parser"
 	"github.com/spf13/viper"
 )
 
@@ -152,6 +158,78 @@ func TestInputFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	traceFile := filepath.Join(dir, "trace.txt")
+	viper.Set("policy", "testdata/policy/test_policy.rego")
+	viper.Set("input", "")
+	viper.Set(test.CombineConfigFlagName, false)
+	viper.Set("trace", true)
+	viper.Set("trace-file", traceFile)
+	defer viper.Set("trace", false)
+	defer viper.Set("trace-file", "")
+
+	cmd := test.NewTestCommand(func(int) {}, func() test.OutputManager {
+		return new(testfakes.FakeOutputManager)
+	})
+	cmd.Run(cmd, []string{"testdata/deployment.yaml"})
+
+	contents, err := ioutil.ReadFile(traceFile)
+	if err != nil {
+		t.Fatalf("trace file should have been written: %v", err)
+	}
+
+	if !strings.Contains(string(contents), "# Trace of data.main.deny_wrongname for testdata/deployment.yaml") {
+		t.Errorf("trace should be grouped by rule and file, got:\n%s", contents)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	coverageFile := filepath.Join(dir, "coverage.json")
+	viper.Set("policy", "testdata/policy/test_policy.rego")
+	viper.Set("input", "")
+	viper.Set(test.CombineConfigFlagName, false)
+	viper.Set("coverage", true)
+	viper.Set("coverage-format", "json")
+	viper.Set("coverage-file", coverageFile)
+	defer viper.Set("coverage", false)
+	defer viper.Set("coverage-format", "")
+	defer viper.Set("coverage-file", "")
+
+	cmd := test.NewTestCommand(func(int) {}, func() test.OutputManager {
+		return new(testfakes.FakeOutputManager)
+	})
+	cmd.Run(cmd, []string{"testdata/deployment.yaml"})
+
+	contents, err := ioutil.ReadFile(coverageFile)
+	if err != nil {
+		t.Fatalf("coverage report should have been written: %v", err)
+	}
+
+	var report struct {
+		Files map[string]interface{} `json:"files"`
+	}
+	if err := json.Unmarshal(contents, &report); err != nil {
+		t.Fatalf("coverage report should be valid JSON: %v", err)
+	}
+
+	if _, ok := report.Files["test_policy.rego"]; !ok {
+		t.Errorf("coverage report should include the policy module, got:\n%s", contents)
+	}
+}
+
 func TestFailQuery(t *testing.T) {
 
 	tests := []struct {
@@ -176,3 +254,184 @@ func TestFailQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildCompilerSourceDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sources := map[string]string{
+		"registry.example.com_kubernetes": "package main\n\ndeny[msg] { msg = \"kubernetes\" }\n",
+		"registry.example.com_docker":     "package main\n\ndeny[msg] { msg = \"docker\" }\n",
+	}
+	for source, policy := range sources {
+		policyDir := filepath.Join(dir, source, "policies")
+		if err := os.MkdirAll(policyDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(policyDir, "main.rego"), []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compiler, err := test.BuildCompiler(dir)
+	if err != nil {
+		t.Fatalf("policies from separate sources should compile together: %v", err)
+	}
+
+	for source := range sources {
+		name := source + "/policies/main.rego"
+		if _, ok := compiler.Modules[name]; !ok {
+			t.Errorf("Expected module %v to be loaded", name)
+		}
+	}
+}
+
+func TestGetConfigurationsKustomization(t *testing.T) {
+	overlay := filepath.Join("..", "..", "kustomize", "testdata", "overlay")
+	configurations, err := test.GetConfigurations("", []string{overlay})
+	if err != nil {
+		t.Fatalf("Unable to get configurations: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(overlay, "canary.yaml") + " (Deployment/canary/production-hello)",
+		filepath.Join(overlay, "service.yaml") + " (Service/production-hello)",
+		filepath.Join(overlay, "service.yaml") + " (Service/production-hello-internal)",
+		filepath.Join(overlay, "..", "base", "deployment.yaml") + " (Deployment/production-hello)",
+	}
+	for _, name := range expected {
+		if _, ok := configurations[name]; !ok {
+			t.Errorf("Expected a configuration named %s, got %v", name, configurations)
+		}
+	}
+	if len(configurations) != len(expected)+1 {
+		t.Errorf("Expected %d configurations including the generated ConfigMap, got %d", len(expected)+1, len(configurations))
+	}
+}
+
+func TestHelmChartExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// only the Service rendered from the chart fails, alongside the passing
+	// Deployment, so the exit code must not depend on which is tested last
+	policy := filepath.Join(dir, "policy.rego")
+	if err := ioutil.WriteFile(policy, []byte("package main\n\ndeny[msg] {\n  input.kind == \"Service\"\n  msg = \"no services\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chart := filepath.Join("..", "..", "helm", "testdata")
+	viper.Set("namespace", "main")
+	viper.Set("policy", policy)
+	viper.Set("input", "")
+	viper.Set(test.CombineConfigFlagName, false)
+	viper.Set("helm-chart", filepath.Join(chart, "chart"))
+	viper.Set("helm-values", []string{filepath.Join(chart, "production.yaml")})
+	defer viper.Set("helm-chart", "")
+	defer viper.Set("helm-values", []string{})
+
+	for i := 0; i < 10; i++ {
+		exitCode := 0
+		var outputPrinter *testfakes.FakeOutputManager
+		cmd := test.NewTestCommand(func(code int) {
+			exitCode = code
+		}, func() test.OutputManager {
+			outputPrinter = new(testfakes.FakeOutputManager)
+			return outputPrinter
+		})
+		cmd.Run(cmd, []string{})
+
+		if outputPrinter.PutCallCount() < 2 {
+			t.Fatalf("Expected every rendered template to be tested, got %d", outputPrinter.PutCallCount())
+		}
+		if exitCode != 1 {
+			t.Fatalf("Expected the failing Service to give exit code 1, got %d", exitCode)
+		}
+	}
+}
+
+func TestHelmValuesShorthand(t *testing.T) {
+	cmd := test.NewTestCommand(func(int) {}, func() test.OutputManager {
+		return new(testfakes.FakeOutputManager)
+	})
+
+	if err := cmd.Flags().Parse([]string{"-f", "values.yaml", "--helm-values", "production.yaml"}); err != nil {
+		t.Fatalf("the test command should accept -f and --helm-values: %v", err)
+	}
+
+	values, err := cmd.Flags().GetStringSlice("helm-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != "values.yaml" || values[1] != "production.yaml" {
+		t.Errorf("Expected both values files, got %v", values)
+	}
+}
+
+func TestKustomizationExitCode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// only one of the resources built from the kustomization fails, so the
+	// exit code must not depend on which is tested last
+	policy := filepath.Join(dir, "policy.rego")
+	if err := ioutil.WriteFile(policy, []byte("package main\n\ndeny[msg] {\n  input.metadata.name == \"production-hello-internal\"\n  msg = \"no internal services\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("namespace", "main")
+	viper.Set("policy", policy)
+	viper.Set("input", "")
+	viper.Set(test.CombineConfigFlagName, false)
+
+	overlay := filepath.Join("..", "..", "kustomize", "testdata", "overlay")
+	for i := 0; i < 10; i++ {
+		exitCode := 0
+		var outputPrinter *testfakes.FakeOutputManager
+		cmd := test.NewTestCommand(func(code int) {
+			exitCode = code
+		}, func() test.OutputManager {
+			outputPrinter = new(testfakes.FakeOutputManager)
+			return outputPrinter
+		})
+		cmd.Run(cmd, []string{overlay})
+
+		if outputPrinter.PutCallCount() < 2 {
+			t.Fatalf("Expected every resource to be tested, got %d", outputPrinter.PutCallCount())
+		}
+		if exitCode != 1 {
+			t.Fatalf("Expected the failing Service to give exit code 1, got %d", exitCode)
+		}
+	}
+}
+
+func TestParserOptionsComposeEnv(t *testing.T) {
+	os.Setenv("CONFTEST_COMPOSE_TAG", "environment")
+	defer os.Unsetenv("CONFTEST_COMPOSE_TAG")
+	viper.Set("compose-env", []string{"CONFTEST_COMPOSE_TAG=flag"})
+	defer viper.Set("compose-env", []string{})
+
+	options := test.ParserOptions()
+	env := options.ComposeEnv
+	if len(env) < 2 || env[len(env)-1] != "CONFTEST_COMPOSE_TAG=flag" {
+		t.Fatalf("Expected the --compose-env flags after the environment, got %v", env)
+	}
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "=") {
+			t.Errorf("Expected variables without a name to be skipped, got %q", variable)
+		}
+	}
+
+	if _, err := parser.GetParserWithOptions("compose", options); err != nil {
+		t.Errorf("the environment should be accepted by the compose parser: %v", err)
+	}
+}
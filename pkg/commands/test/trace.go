@@ -0,0 +1,78 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/spf13/viper"
+)
+
+// Trace holds the evaluation trace of a single rule query.
+type Trace struct {
+	Query string
+	Lines []string
+}
+
+func newTrace(query string, events []*topdown.Event) Trace {
+	var buf bytes.Buffer
+	topdown.PrettyTrace(&buf, events)
+
+	return Trace{
+		Query: query,
+		Lines: strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"),
+	}
+}
+
+// shouldTrace reports whether the given rule should be traced, based on the
+// trace flag and the optional list of rules to trace.
+func shouldTrace(rule string) bool {
+	if !viper.GetBool("trace") {
+		return false
+	}
+
+	rules := viper.GetStringSlice("trace-rule")
+	if len(rules) == 0 {
+		return true
+	}
+
+	return stringInSlice(rule, rules)
+}
+
+// getTraceWriter returns where traces should be written. Traces go to the
+// trace file when one is given, otherwise to stderr so they do not end up
+// mixed with the results on stdout. JSON output embeds the traces, so they are
+// not written again unless a trace file is requested.
+func getTraceWriter() (io.WriteCloser, error) {
+	if path := viper.GetString("trace-file"); path != "" {
+		return os.Create(path)
+	}
+
+	if viper.GetString("output") == OutputJSON {
+		return nopWriteCloser{ioutil.Discard}, nil
+	}
+
+	return nopWriteCloser{os.Stderr}, nil
+}
+
+// writeTraces writes the traces for a file, grouped by rule query.
+func writeTraces(w io.Writer, fileName string, traces []Trace) error {
+	for _, trace := range traces {
+		_, err := fmt.Fprintf(w, "# Trace of %s for %s\n%s\n", trace.Query, fileName, strings.Join(trace.Lines, "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
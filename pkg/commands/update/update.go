@@ -23,6 +23,14 @@ func NewUpdateCommand() *cobra.Command {
 		Use:   "update",
 		Short: "Download policy from registry",
 		Long:  `Download latest policy files according to configuration file`,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlag("verify-key", cmd.Flags().Lookup("verify-key"))
+			viper.BindPFlag("clean", cmd.Flags().Lookup("clean"))
+			viper.BindPFlag("offline", cmd.Flags().Lookup("offline"))
+			viper.BindPFlag("cache-ttl", cmd.Flags().Lookup("cache-ttl"))
+			policy.BindRegistryFlags(cmd)
+			viper.BindPFlag("locked", cmd.Flags().Lookup("locked"))
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 			var config Config
@@ -31,9 +39,39 @@ func NewUpdateCommand() *cobra.Command {
 				log.G(ctx).Fatal(err)
 			}
 
-			policy.DownloadPolicy(ctx, config.Policies)
+			if viper.GetBool("locked") {
+				lock, err := policy.ReadLock(policy.LockFile)
+				if err != nil {
+					log.G(ctx).Fatal(err)
+				}
+
+				policies, err := policy.LockedPolicies(config.Policies, lock)
+				if err != nil {
+					log.G(ctx).Fatalf("Configuration does not match %s: %v", policy.LockFile, err)
+				}
+
+				if _, err := policy.DownloadPolicy(ctx, policies); err != nil {
+					log.G(ctx).Fatal(err)
+				}
+				return
+			}
+
+			downloaded, err := policy.DownloadPolicy(ctx, config.Policies)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+			if err := policy.WriteLock(policy.LockFile, policy.Lock{Policies: downloaded}); err != nil {
+				log.G(ctx).Fatal(err)
+			}
 		},
 	}
 
+	command.Flags().BoolP("locked", "", false, "download exactly the policy digests recorded in the lock file")
+	command.Flags().BoolP("offline", "", false, "use cached policies without contacting their source")
+	command.Flags().DurationP("cache-ttl", "", 0, "reuse cached policies downloaded within this duration")
+	command.Flags().BoolP("clean", "", false, "remove the directory of each policy before writing it")
+	command.Flags().StringSliceP("verify-key", "", []string{}, "path to a PEM encoded public key used to verify policy signatures")
+	policy.AddRegistryFlags(command)
+
 	return command
 }
@@ -11,4 +11,8 @@ const (
 	OpenPolicyAgentManifestLayerMediaType = "application/vnd.cncf.openpolicyagent.manifest.layer.v1+json"
 	OpenPolicyAgentPolicyLayerMediaType   = "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego"
 	OpenPolicyAgentDataLayerMediaType     = "application/vnd.cncf.openpolicyagent.data.layer.v1+json"
+	OpenPolicyAgentYAMLDataLayerMediaType = "application/vnd.cncf.openpolicyagent.data.layer.v1+yaml"
+	ConftestSignatureLayerMediaType       = "application/vnd.conftest.signature.layer.v1"
+
+	ConftestVersionAnnotation = "com.github.instrumenta.conftest.version"
 )
@@ -0,0 +1,244 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Metadata is the contents of a chart's Chart.yaml
+type Metadata struct {
+	APIVersion  string   `json:"apiVersion"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	AppVersion  string   `json:"appVersion"`
+	KubeVersion string   `json:"kubeVersion"`
+	Description string   `json:"description"`
+	Home        string   `json:"home"`
+	Icon        string   `json:"icon"`
+	Keywords    []string `json:"keywords"`
+	Sources     []string `json:"sources"`
+	Deprecated  bool     `json:"deprecated"`
+}
+
+// Dependency is a subchart listed in a chart's requirements.yaml
+type Dependency struct {
+	Name         string        `json:"name"`
+	Version      string        `json:"version"`
+	Repository   string        `json:"repository"`
+	Condition    string        `json:"condition"`
+	Tags         []string      `json:"tags"`
+	Alias        string        `json:"alias"`
+	ImportValues []interface{} `json:"import-values"`
+}
+
+// Chart is a chart loaded into memory, with its subcharts
+type Chart struct {
+	Metadata     Metadata
+	Values       map[string]interface{}
+	Templates    map[string][]byte // keyed by their path in the chart, such as templates/service.yaml
+	Files        Files
+	Charts       []*Chart
+	Dependencies []Dependency
+}
+
+// Files are the files of a chart that are neither templates nor chart
+// metadata. They are available to templates as .Files.
+type Files map[string][]byte
+
+// Get returns the contents of a file, or an empty string if there is no such
+// file
+func (f Files) Get(name string) string {
+	return string(f[name])
+}
+
+// GetBytes returns the contents of a file
+func (f Files) GetBytes(name string) []byte {
+	return f[name]
+}
+
+// Lines returns the lines of a file
+func (f Files) Lines(name string) []string {
+	if len(f[name]) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(string(f[name]), "\n"), "\n")
+}
+
+// Glob returns the files whose name matches the pattern
+func (f Files) Glob(pattern string) Files {
+	matched := Files{}
+	for name, contents := range f {
+		if ok, _ := path.Match(pattern, name); ok {
+			matched[name] = contents
+		}
+	}
+
+	return matched
+}
+
+// LoadChart loads a chart from a directory or from a packaged .tgz archive
+func LoadChart(chartPath string) (*Chart, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		archive, err := os.Open(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+
+		return loadArchive(archive)
+	}
+
+	files := map[string][]byte{}
+	err = filepath.Walk(chartPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(chartPath, file)
+		if err != nil {
+			return err
+		}
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = contents
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loadFiles(files)
+}
+
+// loadArchive loads a packaged chart, whose files are all found in a
+// directory named after the chart
+func loadArchive(r io.Reader) (*Chart, error) {
+	uncompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read chart archive: %v", err)
+	}
+	defer uncompressed.Close()
+
+	files := map[string][]byte{}
+	archive := tar.NewReader(uncompressed)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read chart archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		parts := strings.SplitN(path.Clean(header.Name), "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		contents, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read %s from chart archive: %v", header.Name, err)
+		}
+		files[parts[1]] = contents
+	}
+
+	return loadFiles(files)
+}
+
+// loadFiles builds a chart from its files, keyed by their path in the chart
+func loadFiles(files map[string][]byte) (*Chart, error) {
+	metadata, ok := files["Chart.yaml"]
+	if !ok {
+		return nil, fmt.Errorf("no Chart.yaml found")
+	}
+
+	chart := &Chart{Templates: map[string][]byte{}, Files: Files{}}
+	if err := yaml.Unmarshal(metadata, &chart.Metadata); err != nil {
+		return nil, fmt.Errorf("Unable to parse Chart.yaml: %v", err)
+	}
+	if chart.Metadata.Name == "" {
+		return nil, fmt.Errorf("Chart.yaml does not set the name of the chart")
+	}
+
+	subcharts := map[string]map[string][]byte{}
+	for name, contents := range files {
+		switch {
+		case name == "Chart.yaml", name == ".helmignore", name == "requirements.lock":
+			// read above, or not needed to render the templates
+		case name == "requirements.yaml":
+			var requirements struct {
+				Dependencies []Dependency `json:"dependencies"`
+			}
+			if err := yaml.Unmarshal(contents, &requirements); err != nil {
+				return nil, fmt.Errorf("Unable to parse requirements.yaml of chart %s: %v", chart.Metadata.Name, err)
+			}
+			chart.Dependencies = requirements.Dependencies
+		case name == "values.yaml":
+			if err := yaml.Unmarshal(contents, &chart.Values); err != nil {
+				return nil, fmt.Errorf("Unable to parse values.yaml of chart %s: %v", chart.Metadata.Name, err)
+			}
+		case strings.HasPrefix(name, "templates/"):
+			chart.Templates[name] = contents
+		case strings.HasPrefix(name, "charts/"):
+			parts := strings.SplitN(strings.TrimPrefix(name, "charts/"), "/", 2)
+			if len(parts) == 2 {
+				if subcharts[parts[0]] == nil {
+					subcharts[parts[0]] = map[string][]byte{}
+				}
+				subcharts[parts[0]][parts[1]] = contents
+				continue
+			}
+
+			if strings.HasSuffix(name, ".tgz") {
+				subchart, err := loadArchive(bytes.NewReader(contents))
+				if err != nil {
+					return nil, fmt.Errorf("Unable to load subchart %s: %v", name, err)
+				}
+				chart.Charts = append(chart.Charts, subchart)
+			}
+		default:
+			chart.Files[name] = contents
+		}
+	}
+
+	if chart.Values == nil {
+		chart.Values = map[string]interface{}{}
+	}
+
+	for name, subchartFiles := range subcharts {
+		subchart, err := loadFiles(subchartFiles)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load subchart %s: %v", name, err)
+		}
+		chart.Charts = append(chart.Charts, subchart)
+	}
+	sort.Slice(chart.Charts, func(i, j int) bool {
+		return chart.Charts[i].Metadata.Name < chart.Charts[j].Metadata.Name
+	})
+
+	return chart, nil
+}
@@ -0,0 +1,136 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/ghodss/yaml"
+)
+
+// maxIncludeDepth is how deeply include, or tpl, may nest a template within
+// itself before rendering fails, the same limit as Helm's
+const maxIncludeDepth = 1000
+
+// funcMap returns the functions available to chart templates: the sprig
+// functions, without those reading the environment, and the functions Helm
+// adds to them. include and tpl render templates defined in t.
+func funcMap(t *template.Template) template.FuncMap {
+	return templateFuncs(t, map[string]int{})
+}
+
+// templateFuncs returns the functions of funcMap, counting in included how
+// many times each template is being included, so that a template including
+// itself fails instead of overflowing the stack
+func templateFuncs(t *template.Template, included map[string]int) template.FuncMap {
+	f := sprig.TxtFuncMap()
+	delete(f, "env")
+	delete(f, "expandenv")
+
+	extra := template.FuncMap{
+		"toYaml":   toYAML,
+		"fromYaml": fromYAML,
+		"toJson":   toJSON,
+		"fromJson": fromJSON,
+		"required": required,
+		"include": func(name string, data interface{}) (string, error) {
+			if included[name] >= maxIncludeDepth {
+				return "", fmt.Errorf("Unable to include %s: nested more than %d times", name, maxIncludeDepth)
+			}
+			included[name]++
+			defer func() { included[name]-- }()
+
+			var buf bytes.Buffer
+			if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+		"tpl": func(source string, data interface{}) (string, error) {
+			// values rendered by tpl can call tpl again, so they count as
+			// including the inline template
+			if included["tpl"] >= maxIncludeDepth {
+				return "", fmt.Errorf("Unable to render tpl value: nested more than %d times", maxIncludeDepth)
+			}
+			included["tpl"]++
+			defer func() { included["tpl"]-- }()
+
+			inline := template.New("tpl").Option("missingkey=zero").Funcs(templateFuncs(t, included))
+			for _, defined := range t.Templates() {
+				if defined.Tree == nil {
+					continue
+				}
+				if _, err := inline.AddParseTree(defined.Name(), defined.Tree); err != nil {
+					return "", err
+				}
+			}
+
+			if _, err := inline.Parse(source); err != nil {
+				return "", fmt.Errorf("Unable to parse tpl value: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := inline.Execute(&buf, data); err != nil {
+				return "", err
+			}
+			return strings.Replace(buf.String(), "<no value>", "", -1), nil
+		},
+	}
+
+	for name, function := range extra {
+		f[name] = function
+	}
+
+	return f
+}
+
+func toYAML(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+func fromYAML(s string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+
+	return m
+}
+
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+func fromJSON(s string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+
+	return m
+}
+
+// required fails rendering with the message when the value is missing
+func required(message string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, errors.New(message)
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return nil, errors.New(message)
+	}
+
+	return value, nil
+}
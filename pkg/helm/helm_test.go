@@ -0,0 +1,262 @@
+package helm
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	testTable := []struct {
+		name       string
+		valueFiles []string
+		expected   map[string][]string
+	}{
+		{
+			name:       "default values",
+			valueFiles: nil,
+			expected: map[string][]string{
+				"web/templates/deployment.yaml": {
+					"name: release-name-web",
+					`version: "1.16"`,
+					"replicas: 1",
+					`image: "nginx:stable"`,
+				},
+				"web/charts/cache/templates/configmap.yaml": {
+					"name: RELEASE-NAME-cache",
+					`size: "64"`,
+					"environment: development",
+				},
+			},
+		},
+		{
+			name:       "values file",
+			valueFiles: []string{"testdata/production.yaml"},
+			expected: map[string][]string{
+				"web/templates/deployment.yaml": {
+					"replicas: 3",
+					`image: "nginx:stable"`,
+				},
+				"web/templates/service.yaml": {
+					"kind: Service",
+					"- port: 80",
+				},
+				"web/charts/cache/templates/configmap.yaml": {
+					`size: "512"`,
+					"environment: production",
+				},
+			},
+		},
+		{
+			name:       "disabled subchart",
+			valueFiles: []string{"testdata/disabled.yaml"},
+			expected: map[string][]string{
+				"web/templates/deployment.yaml": {
+					"replicas: 1",
+				},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := Render("testdata/chart", test.valueFiles)
+			if err != nil {
+				t.Fatalf("rendering should not have thrown an error: %v", err)
+			}
+
+			var names, expectedNames []string
+			for name := range rendered {
+				names = append(names, name)
+			}
+			for name := range test.expected {
+				expectedNames = append(expectedNames, name)
+			}
+			sort.Strings(names)
+			sort.Strings(expectedNames)
+			if !reflect.DeepEqual(names, expectedNames) {
+				t.Fatalf("Expected templates %v, got %v", expectedNames, names)
+			}
+
+			for name, lines := range test.expected {
+				for _, line := range lines {
+					if !strings.Contains(rendered[name], line) {
+						t.Errorf("Expected %s to contain %q, got:\n%s", name, line, rendered[name])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderFunctions(t *testing.T) {
+	chart := &Chart{
+		Metadata: Metadata{Name: "functions"},
+		Templates: map[string][]byte{
+			"templates/configmap.yaml": []byte(`data:
+  greeting: {{ tpl .Values.greeting . }}
+  settings: {{ .Values.settings | toJson }}
+  owner: {{ required "owner is required" .Values.owner }}
+`),
+		},
+	}
+	values := map[string]interface{}{
+		"greeting": "hello {{ .Values.owner }}",
+		"owner":    "conftest",
+		"settings": map[string]interface{}{"debug": true},
+	}
+
+	rendered, err := renderChart(chart, values)
+	if err != nil {
+		t.Fatalf("rendering should not have thrown an error: %v", err)
+	}
+
+	expected := `data:
+  greeting: hello conftest
+  settings: {"debug":true}
+  owner: conftest`
+	if rendered["functions/templates/configmap.yaml"] != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, rendered["functions/templates/configmap.yaml"])
+	}
+
+	delete(values, "owner")
+	if _, err := renderChart(chart, values); err == nil || !strings.Contains(err.Error(), "owner is required") {
+		t.Errorf("Expected the required value to fail rendering, got %v", err)
+	}
+}
+
+func TestRenderRecursion(t *testing.T) {
+	testTable := []struct {
+		name     string
+		template string
+		values   map[string]interface{}
+	}{
+		{
+			name:     "include",
+			template: `{{ define "loop" }}{{ include "loop" . }}{{ end }}{{ include "loop" . }}`,
+		},
+		{
+			name:     "tpl",
+			template: `{{ tpl .Values.loop . }}`,
+			values:   map[string]interface{}{"loop": "{{ tpl .Values.loop . }}"},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			chart := &Chart{
+				Metadata:  Metadata{Name: "recursion"},
+				Templates: map[string][]byte{"templates/configmap.yaml": []byte(test.template)},
+			}
+
+			_, err := renderChart(chart, test.values)
+			if err == nil || !strings.Contains(err.Error(), "nested more than") {
+				t.Errorf("Expected recursive templates to fail rendering, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRenderRequirements(t *testing.T) {
+	subchart := &Chart{
+		Metadata:  Metadata{Name: "cache"},
+		Values:    map[string]interface{}{},
+		Templates: map[string][]byte{"templates/configmap.yaml": []byte("name: {{ .Chart.Name }}")},
+	}
+	dependencies := []Dependency{
+		{Name: "cache", Condition: "cache.enabled,global.cache", Tags: []string{"backend"}},
+		{Name: "cache", Alias: "sessions", Tags: []string{"backend", "sessions"}},
+	}
+
+	testTable := []struct {
+		name     string
+		values   map[string]interface{}
+		expected []string
+	}{
+		{
+			name:     "enabled by default",
+			values:   map[string]interface{}{},
+			expected: []string{"web/charts/cache/templates/configmap.yaml", "web/charts/sessions/templates/configmap.yaml"},
+		},
+		{
+			name:     "disabled by condition",
+			values:   map[string]interface{}{"cache": map[string]interface{}{"enabled": false}},
+			expected: []string{"web/charts/sessions/templates/configmap.yaml"},
+		},
+		{
+			name:     "disabled by tags",
+			values:   map[string]interface{}{"tags": map[string]interface{}{"backend": false}},
+			expected: nil,
+		},
+		{
+			name: "condition overrides tags",
+			values: map[string]interface{}{
+				"tags":   map[string]interface{}{"backend": false},
+				"global": map[string]interface{}{"cache": true},
+			},
+			expected: []string{"web/charts/cache/templates/configmap.yaml"},
+		},
+		{
+			name:     "any tag enables",
+			values:   map[string]interface{}{"tags": map[string]interface{}{"backend": false, "sessions": true}},
+			expected: []string{"web/charts/sessions/templates/configmap.yaml"},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			chart := &Chart{
+				Metadata:     Metadata{Name: "web"},
+				Charts:       []*Chart{subchart},
+				Dependencies: dependencies,
+			}
+			rendered, err := renderChart(chart, test.values)
+			if err != nil {
+				t.Fatalf("rendering should not have thrown an error: %v", err)
+			}
+
+			var names []string
+			for name := range rendered {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("Expected templates %v, got %v", test.expected, names)
+			}
+			if sessions, ok := rendered["web/charts/sessions/templates/configmap.yaml"]; ok && sessions != "name: sessions" {
+				t.Errorf("Expected the aliased subchart to be renamed, got %q", sessions)
+			}
+		})
+	}
+
+	missing := &Chart{Metadata: Metadata{Name: "web"}, Dependencies: []Dependency{{Name: "database"}}}
+	if _, err := renderChart(missing, map[string]interface{}{}); err == nil {
+		t.Error("Expected a dependency missing from the charts directory to fail rendering")
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]interface{}{
+		"image":   map[string]interface{}{"repository": "nginx", "tag": "stable"},
+		"service": map[string]interface{}{"port": 80},
+		"debug":   true,
+	}
+	override := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "1.16"},
+		"debug": nil,
+	}
+
+	expected := map[string]interface{}{
+		"image":   map[string]interface{}{"repository": "nginx", "tag": "1.16"},
+		"service": map[string]interface{}{"port": 80},
+	}
+
+	merged := mergeValues(base, override)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+	if base["image"].(map[string]interface{})["tag"] != "stable" {
+		t.Error("Merging values should not modify the base values")
+	}
+}
@@ -0,0 +1,362 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+)
+
+// Release describes the release a chart is rendered for, as .Release
+type Release struct {
+	Name      string
+	Namespace string
+	Service   string
+	Revision  int
+	IsInstall bool
+	IsUpgrade bool
+}
+
+// Capabilities describes the cluster a chart is rendered for, as
+// .Capabilities
+type Capabilities struct {
+	APIVersions VersionSet
+	KubeVersion KubeVersion
+}
+
+// VersionSet is the set of API versions supported by the cluster
+type VersionSet []string
+
+// Has returns whether the API version is supported
+func (v VersionSet) Has(apiVersion string) bool {
+	for _, version := range v {
+		if version == apiVersion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KubeVersion is the version of the cluster
+type KubeVersion struct {
+	Major      string
+	Minor      string
+	GitVersion string
+}
+
+func (k KubeVersion) String() string {
+	return k.GitVersion
+}
+
+// Template is the template being rendered, as .Template
+type Template struct {
+	Name     string
+	BasePath string
+}
+
+// defaultRelease and defaultCapabilities match what helm template uses when
+// not connected to a cluster
+var (
+	defaultRelease = Release{
+		Name:      "RELEASE-NAME",
+		Namespace: "default",
+		Service:   "Tiller",
+		Revision:  1,
+		IsInstall: true,
+	}
+
+	defaultCapabilities = Capabilities{
+		APIVersions: VersionSet{
+			"v1",
+			"admissionregistration.k8s.io/v1beta1",
+			"apiextensions.k8s.io/v1beta1",
+			"apps/v1",
+			"apps/v1beta1",
+			"apps/v1beta2",
+			"autoscaling/v1",
+			"autoscaling/v2beta1",
+			"autoscaling/v2beta2",
+			"batch/v1",
+			"batch/v1beta1",
+			"extensions/v1beta1",
+			"networking.k8s.io/v1",
+			"policy/v1beta1",
+			"rbac.authorization.k8s.io/v1",
+			"rbac.authorization.k8s.io/v1beta1",
+			"storage.k8s.io/v1",
+			"storage.k8s.io/v1beta1",
+		},
+		KubeVersion: KubeVersion{Major: "1", Minor: "14", GitVersion: "v1.14.0"},
+	}
+)
+
+type renderable struct {
+	name   string
+	source string
+	data   map[string]interface{}
+}
+
+// Render renders the templates of the chart at chartPath and of its
+// subcharts. The values of the chart are overridden by each of the values
+// files in order. The rendered manifests are keyed by the name of the template
+// that produced them, such as mychart/templates/service.yaml, and templates
+// that render nothing are left out.
+func Render(chartPath string, valueFiles []string) (map[string]string, error) {
+	chart, err := LoadChart(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load chart %s: %v", chartPath, err)
+	}
+
+	values := chart.Values
+	for _, valueFile := range valueFiles {
+		contents, err := ioutil.ReadFile(valueFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read values file: %v", err)
+		}
+
+		var override map[string]interface{}
+		if err := yaml.Unmarshal(contents, &override); err != nil {
+			return nil, fmt.Errorf("Unable to parse values file %s: %v", valueFile, err)
+		}
+		values = mergeValues(values, override)
+	}
+
+	return renderChart(chart, values)
+}
+
+func renderChart(chart *Chart, values map[string]interface{}) (map[string]string, error) {
+	var renderables []renderable
+	tags, _ := values["tags"].(map[string]interface{})
+	if err := collectTemplates(chart, chart.Metadata.Name, values, tags, &renderables); err != nil {
+		return nil, err
+	}
+
+	t := template.New("gotpl").Option("missingkey=zero")
+	t.Funcs(funcMap(t))
+	for _, r := range renderables {
+		if _, err := t.New(r.name).Parse(r.source); err != nil {
+			return nil, fmt.Errorf("Unable to parse template %s: %v", r.name, err)
+		}
+	}
+
+	rendered := map[string]string{}
+	for _, r := range renderables {
+		base := path.Base(r.name)
+		if strings.HasPrefix(base, "_") || base == "NOTES.txt" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, r.name, r.data); err != nil {
+			return nil, fmt.Errorf("Unable to render template %s: %v", r.name, err)
+		}
+
+		manifest := cleanManifest(strings.Replace(buf.String(), "<no value>", "", -1))
+		if manifest != "" {
+			rendered[r.name] = manifest
+		}
+	}
+
+	return rendered, nil
+}
+
+// collectTemplates adds the templates of a chart and its enabled subcharts,
+// with the data each is rendered with, to renderables
+func collectTemplates(chart *Chart, name string, values, tags map[string]interface{}, renderables *[]renderable) error {
+	var templateNames []string
+	for templateName := range chart.Templates {
+		templateNames = append(templateNames, templateName)
+	}
+	sort.Strings(templateNames)
+
+	for _, templateName := range templateNames {
+		fullName := name + "/" + templateName
+		*renderables = append(*renderables, renderable{
+			name:   fullName,
+			source: string(chart.Templates[templateName]),
+			data: map[string]interface{}{
+				"Values":       values,
+				"Chart":        chart.Metadata,
+				"Release":      defaultRelease,
+				"Capabilities": defaultCapabilities,
+				"Files":        chart.Files,
+				"Template":     Template{Name: fullName, BasePath: name + "/templates"},
+			},
+		})
+	}
+
+	subcharts, err := enabledSubcharts(chart, values, tags)
+	if err != nil {
+		return err
+	}
+	for _, subchart := range subcharts {
+		if err := collectTemplates(subchart, name+"/charts/"+subchart.Metadata.Name, subchartValues(values, subchart), tags, renderables); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enabledSubcharts returns the subcharts of a chart that are rendered.
+// Subcharts listed in requirements.yaml are renamed to their alias, and are
+// left out when their condition or tags disable them. Subcharts that are not
+// listed are always rendered.
+func enabledSubcharts(chart *Chart, values, tags map[string]interface{}) ([]*Chart, error) {
+	byName := map[string]*Chart{}
+	for _, subchart := range chart.Charts {
+		byName[subchart.Metadata.Name] = subchart
+	}
+
+	listed := map[string]bool{}
+	var subcharts []*Chart
+	for _, dependency := range chart.Dependencies {
+		subchart, ok := byName[dependency.Name]
+		if !ok {
+			return nil, fmt.Errorf("Dependency %s of chart %s is missing from its charts directory, run helm dependency update", dependency.Name, chart.Metadata.Name)
+		}
+		if len(dependency.ImportValues) > 0 {
+			return nil, fmt.Errorf("Dependency %s of chart %s uses import-values, which is not supported", dependency.Name, chart.Metadata.Name)
+		}
+		listed[dependency.Name] = true
+
+		if !dependencyEnabled(dependency, values, tags) {
+			continue
+		}
+		if dependency.Alias != "" {
+			aliased := *subchart
+			aliased.Metadata.Name = dependency.Alias
+			subchart = &aliased
+		}
+		subcharts = append(subcharts, subchart)
+	}
+
+	for _, subchart := range chart.Charts {
+		if !listed[subchart.Metadata.Name] {
+			subcharts = append(subcharts, subchart)
+		}
+	}
+	sort.Slice(subcharts, func(i, j int) bool {
+		return subcharts[i].Metadata.Name < subcharts[j].Metadata.Name
+	})
+
+	return subcharts, nil
+}
+
+// dependencyEnabled follows helm: the first path of the condition that is set
+// to a boolean decides, otherwise a dependency is disabled when the tags it
+// lists are only set to false
+func dependencyEnabled(dependency Dependency, values, tags map[string]interface{}) bool {
+	for _, condition := range strings.Split(dependency.Condition, ",") {
+		if enabled, ok := lookupValue(values, strings.TrimSpace(condition)).(bool); ok {
+			return enabled
+		}
+	}
+
+	enabled := true
+	for _, tag := range dependency.Tags {
+		switch tags[tag] {
+		case true:
+			return true
+		case false:
+			enabled = false
+		}
+	}
+
+	return enabled
+}
+
+// lookupValue returns the value at a dotted path such as cache.enabled, or nil
+// if there is none
+func lookupValue(values map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+
+	var value interface{} = values
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	return value
+}
+
+// subchartValues returns the values of a subchart: its own values overridden
+// by those its parent sets under the subchart's name, and the parent's global
+// values
+func subchartValues(parent map[string]interface{}, subchart *Chart) map[string]interface{} {
+	scoped, _ := parent[subchart.Metadata.Name].(map[string]interface{})
+	values := mergeValues(subchart.Values, scoped)
+
+	if global, ok := parent["global"].(map[string]interface{}); ok {
+		subchartGlobal, _ := values["global"].(map[string]interface{})
+		values["global"] = mergeValues(subchartGlobal, global)
+	}
+
+	return values
+}
+
+// mergeValues returns base with override merged into it. Objects are merged
+// recursively, and a null value removes the key.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range override {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		if overrideObject, ok := value.(map[string]interface{}); ok {
+			if baseObject, ok := merged[key].(map[string]interface{}); ok {
+				merged[key] = mergeValues(baseObject, overrideObject)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+
+	return merged
+}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// cleanManifest removes the documents of a rendered template that are empty
+// or only hold comments, which templates commonly produce when a resource is
+// disabled
+func cleanManifest(manifest string) string {
+	var documents []string
+	for _, document := range documentSeparator.Split(manifest, -1) {
+		document = strings.Trim(document, "\r\n")
+		if !emptyDocument(document) {
+			documents = append(documents, document)
+		}
+	}
+
+	return strings.Join(documents, "\n---\n")
+}
+
+func emptyDocument(document string) bool {
+	for _, line := range strings.Split(document, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+
+	return true
+}
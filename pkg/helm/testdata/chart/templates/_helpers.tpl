@@ -0,0 +1,8 @@
+{{- define "web.fullname" -}}
+{{- printf "%s-%s" .Release.Name .Chart.Name | lower | trunc 63 | trimSuffix "-" -}}
+{{- end -}}
+
+{{- define "web.labels" -}}
+app: {{ .Chart.Name }}
+version: {{ .Chart.AppVersion | quote }}
+{{- end -}}
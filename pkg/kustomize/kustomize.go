@@ -0,0 +1,124 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/kustomize/k8sdeps"
+	"sigs.k8s.io/kustomize/pkg/constants"
+	"sigs.k8s.io/kustomize/pkg/fs"
+	"sigs.k8s.io/kustomize/pkg/loader"
+	"sigs.k8s.io/kustomize/pkg/target"
+)
+
+// OriginAnnotation is added to each built resource, recording the file the
+// resource is declared in relative to the kustomization
+const OriginAnnotation = "config.kubernetes.io/origin"
+
+// Resource is a resource built from a kustomization
+type Resource struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Origin    string // the file declaring the resource, or generating it
+	Object    interface{}
+}
+
+// IsKustomization returns whether path is a directory holding a
+// kustomization file
+func IsKustomization(path string) bool {
+	_, err := kustomizationFile(path)
+	return err == nil
+}
+
+// Build builds the kustomization in dir, the same way kustomize build does,
+// and returns the resulting resources sorted by origin, kind, namespace and
+// name
+func Build(dir string) ([]Resource, error) {
+	origins, err := findOrigins(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := k8sdeps.NewFactory()
+	ldr, err := loader.NewLoader(dir, fs.MakeRealFS())
+	if err != nil {
+		return nil, err
+	}
+	defer ldr.Cleanup()
+
+	kt, err := target.NewKustTarget(ldr, factory.ResmapF, factory.TransformerF)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := kt.MakeCustomizedResMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var built []Resource
+	for id, resource := range resources {
+		origin, ok := origins[originKey(id.Gvk().Kind, id.Namespace(), id.Name())]
+		if !ok {
+			origin, _ = kustomizationFile(dir)
+		}
+
+		relative, err := filepath.Rel(dir, origin)
+		if err != nil {
+			return nil, err
+		}
+		annotations := resource.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[OriginAnnotation] = "path: " + filepath.ToSlash(relative)
+		resource.SetAnnotations(annotations)
+
+		contents, err := resource.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var object interface{}
+		if err := json.Unmarshal(contents, &object); err != nil {
+			return nil, fmt.Errorf("Unable to unmarshal %s: %v", id, err)
+		}
+
+		built = append(built, Resource{
+			Kind:      resource.GetKind(),
+			Namespace: id.Namespace(),
+			Name:      resource.GetName(),
+			Origin:    origin,
+			Object:    object,
+		})
+	}
+
+	sort.Slice(built, func(i, j int) bool {
+		if built[i].Origin != built[j].Origin {
+			return built[i].Origin < built[j].Origin
+		}
+		if built[i].Kind != built[j].Kind {
+			return built[i].Kind < built[j].Kind
+		}
+		if built[i].Namespace != built[j].Namespace {
+			return built[i].Namespace < built[j].Namespace
+		}
+		return built[i].Name < built[j].Name
+	})
+
+	return built, nil
+}
+
+// kustomizationFile returns the path of the kustomization file in dir
+func kustomizationFile(dir string) (string, error) {
+	for _, name := range constants.KustomizationFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no kustomization file found in %s", dir)
+}
@@ -0,0 +1,95 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsKustomization(t *testing.T) {
+	testTable := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "testdata/overlay", expected: true},
+		{path: "testdata/overlay/service.yaml", expected: false},
+		{path: "testdata", expected: false},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.path, func(t *testing.T) {
+			if actual := IsKustomization(test.path); actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFindOrigins(t *testing.T) {
+	origins, err := findOrigins(filepath.Join("testdata", "overlay"))
+	if err != nil {
+		t.Fatalf("finding origins should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]string{
+		"Deployment//hello":       filepath.Join("testdata", "base", "deployment.yaml"),
+		"Deployment/canary/hello": filepath.Join("testdata", "overlay", "canary.yaml"),
+		"ConfigMap//settings":     filepath.Join("testdata", "base", "kustomization.yaml"),
+		"Service//hello":          filepath.Join("testdata", "overlay", "service.yaml"),
+		"Service//hello-internal": filepath.Join("testdata", "overlay", "service.yaml"),
+	}
+	if !reflect.DeepEqual(origins, expected) {
+		t.Errorf("Expected %v, got %v", expected, origins)
+	}
+}
+
+func TestFindOriginsNamespace(t *testing.T) {
+	origins := map[string]string{}
+	if err := addOrigins(filepath.Join("testdata", "base"), "production", origins, map[string]bool{}); err != nil {
+		t.Fatalf("finding origins should not have thrown an error: %v", err)
+	}
+
+	for _, key := range []string{"Deployment//hello", "Deployment/production/hello", "ConfigMap/production/settings"} {
+		if _, ok := origins[key]; !ok {
+			t.Errorf("Expected an origin for %s, got %v", key, origins)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	resources, err := Build(filepath.Join("testdata", "overlay"))
+	if err != nil {
+		t.Fatalf("building should not have thrown an error: %v", err)
+	}
+
+	// generated resources have a hash of their contents appended to their
+	// name, so names are compared by prefix
+	expected := []struct {
+		kind, name, origin, annotation string
+	}{
+		{"Deployment", "production-hello", filepath.Join("testdata", "base", "deployment.yaml"), "path: ../base/deployment.yaml"},
+		{"ConfigMap", "production-settings-", filepath.Join("testdata", "base", "kustomization.yaml"), "path: ../base/kustomization.yaml"},
+		{"Deployment", "production-hello", filepath.Join("testdata", "overlay", "canary.yaml"), "path: canary.yaml"},
+		{"Service", "production-hello", filepath.Join("testdata", "overlay", "service.yaml"), "path: service.yaml"},
+		{"Service", "production-hello-internal", filepath.Join("testdata", "overlay", "service.yaml"), "path: service.yaml"},
+	}
+	if len(resources) != len(expected) {
+		t.Fatalf("Expected %d resources, got %d", len(expected), len(resources))
+	}
+
+	for i, resource := range resources {
+		metadata := resource.Object.(map[string]interface{})["metadata"].(map[string]interface{})
+		annotation := metadata["annotations"].(map[string]interface{})[OriginAnnotation]
+
+		if resource.Kind != expected[i].kind || !strings.HasPrefix(resource.Name, expected[i].name) {
+			t.Errorf("Expected %s %s, got %s %s", expected[i].kind, expected[i].name, resource.Kind, resource.Name)
+		}
+		if resource.Origin != expected[i].origin {
+			t.Errorf("Expected %s %s to originate from %s, got %s", resource.Kind, resource.Name, expected[i].origin, resource.Origin)
+		}
+		if annotation != expected[i].annotation {
+			t.Errorf("Expected %s annotation %q, got %v", OriginAnnotation, expected[i].annotation, annotation)
+		}
+	}
+}
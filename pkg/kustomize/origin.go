@@ -0,0 +1,134 @@
+package kustomize
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ghodss/yaml"
+	"sigs.k8s.io/kustomize/pkg/types"
+)
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// findOrigins returns the file each resource of a kustomization and its
+// bases is declared in, keyed by kind, namespace and by name before any prefix
+// or suffix is added. Generated resources originate from the kustomization
+// file generating them. Remote bases are not followed.
+func findOrigins(dir string) (map[string]string, error) {
+	origins := map[string]string{}
+	if err := addOrigins(dir, "", origins, map[string]bool{}); err != nil {
+		return nil, err
+	}
+
+	return origins, nil
+}
+
+// addOrigins adds the origins of the resources of the kustomization in dir.
+// namespace is the namespace set by the kustomizations including it, which
+// takes precedence over the namespace it sets itself.
+func addOrigins(dir string, namespace string, origins map[string]string, visited map[string]bool) error {
+	if visited[dir] {
+		return nil
+	}
+	visited[dir] = true
+
+	file, err := kustomizationFile(dir)
+	if err != nil {
+		return err
+	}
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var kustomization types.Kustomization
+	if err := yaml.Unmarshal(contents, &kustomization); err != nil {
+		return fmt.Errorf("Unable to parse %s: %v", file, err)
+	}
+
+	if namespace == "" {
+		namespace = kustomization.Namespace
+	}
+
+	for _, generator := range kustomization.ConfigMapGenerator {
+		setOrigin(origins, "ConfigMap", generator.Namespace, namespace, generator.Name, file)
+	}
+	for _, generator := range kustomization.SecretGenerator {
+		setOrigin(origins, "Secret", generator.Namespace, namespace, generator.Name, file)
+	}
+
+	var paths []string
+	paths = append(paths, kustomization.Resources...)
+	paths = append(paths, kustomization.Crds...)
+	paths = append(paths, kustomization.Bases...)
+	for _, path := range paths {
+		path = filepath.Join(dir, path)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if info.IsDir() {
+			if err := addOrigins(path, namespace, origins, visited); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := addFileOrigins(path, namespace, origins); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addFileOrigins(path string, namespace string, origins map[string]string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, document := range documentSeparator.Split(string(contents), -1) {
+		var resource struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(document), &resource); err != nil {
+			return fmt.Errorf("Unable to parse %s: %v", path, err)
+		}
+		if resource.Kind != "" {
+			setOrigin(origins, resource.Kind, resource.Metadata.Namespace, namespace, resource.Metadata.Name, path)
+		}
+	}
+
+	return nil
+}
+
+// setOrigin records the first file found for a resource, as overlays are
+// searched before their bases. Resources are recorded under the namespace
+// they declare, and under the namespace set by a kustomization, as that is
+// not applied to cluster scoped resources.
+func setOrigin(origins map[string]string, kind, declared, namespace, name, path string) {
+	namespaces := []string{declared}
+	if namespace != "" {
+		namespaces = append(namespaces, namespace)
+	}
+
+	for _, ns := range namespaces {
+		key := originKey(kind, ns, name)
+		if _, ok := origins[key]; !ok {
+			origins[key] = path
+		}
+	}
+}
+
+func originKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
@@ -0,0 +1,147 @@
+package cloudformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Parser parses CloudFormation templates, including those of the resources
+// section of Serverless Framework configs. The short form of intrinsic
+// functions, such as !Ref and !GetAtt, is converted to the long form, such as
+// Ref and Fn::GetAtt. Each resource is given a LogicalId field holding its
+// logical ID.
+type Parser struct{}
+
+// functions are the intrinsic functions that have a short form, which is the
+// name of the function as a tag
+var functions = map[string]string{
+	"Ref":         "Ref",
+	"Condition":   "Condition",
+	"And":         "Fn::And",
+	"Base64":      "Fn::Base64",
+	"Cidr":        "Fn::Cidr",
+	"Equals":      "Fn::Equals",
+	"FindInMap":   "Fn::FindInMap",
+	"GetAtt":      "Fn::GetAtt",
+	"GetAZs":      "Fn::GetAZs",
+	"If":          "Fn::If",
+	"ImportValue": "Fn::ImportValue",
+	"Join":        "Fn::Join",
+	"Not":         "Fn::Not",
+	"Or":          "Fn::Or",
+	"Select":      "Fn::Select",
+	"Split":       "Fn::Split",
+	"Sub":         "Fn::Sub",
+	"Transform":   "Fn::Transform",
+}
+
+func (c *Parser) Unmarshal(p []byte, v interface{}) error {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(p, &document); err != nil {
+		return fmt.Errorf("Unable to parse CloudFormation template: %v", err)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	if err := expandFunctions(&document); err != nil {
+		return err
+	}
+
+	expanded, err := yamlv3.Marshal(&document)
+	if err != nil {
+		return fmt.Errorf("Unable to encode CloudFormation template: %v", err)
+	}
+
+	var template interface{}
+	if err := yaml.Unmarshal(expanded, &template); err != nil {
+		return fmt.Errorf("Unable to parse YAML from CloudFormation template: %s", err)
+	}
+
+	if object, ok := template.(map[string]interface{}); ok {
+		addLogicalIds(object["Resources"])
+		if resources, ok := object["resources"].(map[string]interface{}); ok {
+			addLogicalIds(resources["Resources"])
+		}
+	}
+
+	j, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse CloudFormation to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from CloudFormation-json: %s", err)
+	}
+
+	return nil
+}
+
+// expandFunctions replaces the nodes tagged with the short form of an
+// intrinsic function with a mapping from the function name to the value
+func expandFunctions(node *yamlv3.Node) error {
+	for _, child := range node.Content {
+		if err := expandFunctions(child); err != nil {
+			return err
+		}
+	}
+
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return nil
+	}
+
+	name := strings.TrimPrefix(node.Tag, "!")
+	function, ok := functions[name]
+	if !ok {
+		return fmt.Errorf("unknown tag %s on line %d", node.Tag, node.Line)
+	}
+
+	value := *node
+	value.Tag = ""
+
+	// the short form of GetAtt is given as LogicalId.Attribute
+	if name == "GetAtt" && value.Kind == yamlv3.ScalarNode {
+		parts := strings.SplitN(value.Value, ".", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid !GetAtt %q on line %d, expected LogicalId.Attribute", value.Value, node.Line)
+		}
+		value = yamlv3.Node{
+			Kind: yamlv3.SequenceNode,
+			Content: []*yamlv3.Node{
+				{Kind: yamlv3.ScalarNode, Style: yamlv3.DoubleQuotedStyle, Value: parts[0]},
+				{Kind: yamlv3.ScalarNode, Style: yamlv3.DoubleQuotedStyle, Value: parts[1]},
+			},
+		}
+	}
+
+	*node = yamlv3.Node{
+		Kind: yamlv3.MappingNode,
+		Content: []*yamlv3.Node{
+			{Kind: yamlv3.ScalarNode, Value: function},
+			&value,
+		},
+		Line:   node.Line,
+		Column: node.Column,
+	}
+
+	return nil
+}
+
+// addLogicalIds sets the LogicalId field of each resource to the key it is
+// declared under
+func addLogicalIds(resources interface{}) {
+	object, ok := resources.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for logicalID, resource := range object {
+		if resource, ok := resource.(map[string]interface{}); ok {
+			resource["LogicalId"] = logicalID
+		}
+	}
+}
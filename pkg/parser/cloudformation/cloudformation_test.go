@@ -0,0 +1,125 @@
+package cloudformation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloudFormationParser(t *testing.T) {
+	parser := &Parser{}
+	sample := `AWSTemplateFormatVersion: "2010-09-09"
+Conditions:
+  IsProduction: !Equals [!Ref Environment, production]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsProduction
+    Properties:
+      BucketName: !Sub "${AWS::StackName}-logs"
+      VersioningConfiguration:
+        Status: !If [IsProduction, Enabled, Suspended]
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      DelaySeconds: 5
+      RedrivePolicy:
+        deadLetterTargetArn: !GetAtt DeadLetterQueue.Arn
+Outputs:
+  Endpoint:
+    Value: !GetAtt Database.Endpoint.Address
+`
+
+	var input interface{}
+	if err := parser.Unmarshal([]byte(sample), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	template := input.(map[string]interface{})
+	resources := template["Resources"].(map[string]interface{})
+
+	bucket := resources["Bucket"].(map[string]interface{})
+	if bucket["LogicalId"] != "Bucket" {
+		t.Errorf("Expected the bucket to have logical ID Bucket, got %v", bucket["LogicalId"])
+	}
+	if bucket["Type"] != "AWS::S3::Bucket" {
+		t.Errorf("Expected the bucket to have type AWS::S3::Bucket, got %v", bucket["Type"])
+	}
+
+	bucketProperties := bucket["Properties"].(map[string]interface{})
+	expectedName := map[string]interface{}{"Fn::Sub": "${AWS::StackName}-logs"}
+	if !reflect.DeepEqual(bucketProperties["BucketName"], expectedName) {
+		t.Errorf("Expected bucket name %v, got %v", expectedName, bucketProperties["BucketName"])
+	}
+
+	expectedStatus := map[string]interface{}{"Fn::If": []interface{}{"IsProduction", "Enabled", "Suspended"}}
+	versioning := bucketProperties["VersioningConfiguration"].(map[string]interface{})
+	if !reflect.DeepEqual(versioning["Status"], expectedStatus) {
+		t.Errorf("Expected versioning status %v, got %v", expectedStatus, versioning["Status"])
+	}
+
+	queueProperties := resources["Queue"].(map[string]interface{})["Properties"].(map[string]interface{})
+	if queueProperties["DelaySeconds"] != float64(5) {
+		t.Errorf("Expected untagged values to keep their type, got %v", queueProperties["DelaySeconds"])
+	}
+	expectedArn := map[string]interface{}{"Fn::GetAtt": []interface{}{"DeadLetterQueue", "Arn"}}
+	redrive := queueProperties["RedrivePolicy"].(map[string]interface{})
+	if !reflect.DeepEqual(redrive["deadLetterTargetArn"], expectedArn) {
+		t.Errorf("Expected dead letter queue %v, got %v", expectedArn, redrive["deadLetterTargetArn"])
+	}
+
+	expectedCondition := map[string]interface{}{"Fn::Equals": []interface{}{map[string]interface{}{"Ref": "Environment"}, "production"}}
+	conditions := template["Conditions"].(map[string]interface{})
+	if !reflect.DeepEqual(conditions["IsProduction"], expectedCondition) {
+		t.Errorf("Expected condition %v, got %v", expectedCondition, conditions["IsProduction"])
+	}
+
+	expectedEndpoint := map[string]interface{}{"Fn::GetAtt": []interface{}{"Database", "Endpoint.Address"}}
+	endpoint := template["Outputs"].(map[string]interface{})["Endpoint"].(map[string]interface{})
+	if !reflect.DeepEqual(endpoint["Value"], expectedEndpoint) {
+		t.Errorf("Expected endpoint %v, got %v", expectedEndpoint, endpoint["Value"])
+	}
+}
+
+func TestCloudFormationParserServerless(t *testing.T) {
+	parser := &Parser{}
+	sample := `service: orders
+provider:
+  name: aws
+resources:
+  Resources:
+    OrdersTable:
+      Type: AWS::DynamoDB::Table
+      Properties:
+        TableName: !Join ["-", [orders, !Ref AWS::Region]]
+`
+
+	var input interface{}
+	if err := parser.Unmarshal([]byte(sample), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	resources := input.(map[string]interface{})["resources"].(map[string]interface{})["Resources"].(map[string]interface{})
+	table := resources["OrdersTable"].(map[string]interface{})
+	if table["LogicalId"] != "OrdersTable" {
+		t.Errorf("Expected the table to have logical ID OrdersTable, got %v", table["LogicalId"])
+	}
+
+	expected := map[string]interface{}{"Fn::Join": []interface{}{"-", []interface{}{"orders", map[string]interface{}{"Ref": "AWS::Region"}}}}
+	properties := table["Properties"].(map[string]interface{})
+	if !reflect.DeepEqual(properties["TableName"], expected) {
+		t.Errorf("Expected table name %v, got %v", expected, properties["TableName"])
+	}
+}
+
+func TestCloudFormationParserUnknownTag(t *testing.T) {
+	parser := &Parser{}
+	sample := `Resources:
+  Bucket:
+    Type: !Unknown AWS::S3::Bucket
+`
+
+	var input interface{}
+	if err := parser.Unmarshal([]byte(sample), &input); err == nil {
+		t.Error("parser should have thrown an error for an unknown tag")
+	}
+}
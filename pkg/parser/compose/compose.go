@@ -0,0 +1,228 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/instrumenta/conftest/pkg/parser/dotenv"
+)
+
+// Parser parses Docker Compose files into the configuration docker-compose
+// runs. Variables are interpolated from the .env file next to the compose
+// file and Env, which takes precedence. The process environment is not read,
+// callers wanting it pass it in Env. Override files are merged, extends is
+// resolved, and the short syntax of ports, volumes, environment and labels is
+// converted to the long syntax. When no Overrides are given,
+// docker-compose.override.yml is merged if it exists next to the compose file.
+type Parser struct {
+	Overrides []string
+	Env       map[string]string
+}
+
+var defaultOverrides = []string{"docker-compose.override.yml", "docker-compose.override.yaml"}
+
+func (c *Parser) Unmarshal(p []byte, v interface{}) error {
+	return c.UnmarshalFile("-", p, v)
+}
+
+// UnmarshalFile parses the compose file read from path. The .env file and
+// default override file are looked for in the directory of path. Neither is
+// read for stdin, as the compose file has no directory of its own; files it
+// extends are still resolved from the working directory.
+func (c *Parser) UnmarshalFile(path string, p []byte, v interface{}) error {
+	dir := "."
+	stdin := path == "" || path == "-"
+	if !stdin {
+		dir = filepath.Dir(path)
+	}
+
+	env, err := c.environment(dir, stdin)
+	if err != nil {
+		return err
+	}
+
+	project, err := load(p, env)
+	if err != nil {
+		return fmt.Errorf("Unable to parse compose file: %v", err)
+	}
+
+	overrides := c.Overrides
+	if len(overrides) == 0 && !stdin {
+		for _, name := range defaultOverrides {
+			override := filepath.Join(dir, name)
+			if _, err := os.Stat(override); err == nil {
+				overrides = []string{override}
+				break
+			}
+		}
+	}
+	for _, override := range overrides {
+		contents, err := ioutil.ReadFile(override)
+		if err != nil {
+			return fmt.Errorf("Unable to read compose override file: %v", err)
+		}
+
+		overrideProject, err := load(contents, env)
+		if err != nil {
+			return fmt.Errorf("Unable to parse compose override file %s: %v", override, err)
+		}
+		project = mergeProject(project, overrideProject)
+	}
+
+	if services, ok := project["services"].(map[string]interface{}); ok {
+		if err := resolveExtends(dir, services, env); err != nil {
+			return err
+		}
+		if err := normalizeServices(services, env); err != nil {
+			return err
+		}
+	}
+
+	j, err := json.Marshal(project)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse compose to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from compose-json: %s", err)
+	}
+
+	return nil
+}
+
+// environment returns the variables used for interpolation, reading the .env
+// file in dir unless the compose file is read from stdin
+func (c *Parser) environment(dir string, stdin bool) (map[string]string, error) {
+	env := map[string]string{}
+
+	if !stdin {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, ".env"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("Unable to read .env file: %v", err)
+		}
+		if err == nil {
+			var variables map[string]interface{}
+			if err := new(dotenv.Parser).Unmarshal(contents, &variables); err != nil {
+				return nil, err
+			}
+			for name, value := range variables {
+				env[name] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	for name, value := range c.Env {
+		env[name] = value
+	}
+
+	return env, nil
+}
+
+// load parses a compose file and interpolates its variables
+func load(p []byte, env map[string]string) (map[string]interface{}, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(p, &document); err != nil {
+		return nil, err
+	}
+
+	interpolated, err := interpolate(document, env)
+	if err != nil {
+		return nil, err
+	}
+
+	project, _ := interpolated.(map[string]interface{})
+	if project == nil {
+		project = map[string]interface{}{}
+	}
+
+	return project, nil
+}
+
+// resolveExtends replaces each service that extends another with the result
+// of merging it into the service it extends
+func resolveExtends(dir string, services map[string]interface{}, env map[string]string) error {
+	for name := range services {
+		resolved, err := extendService(dir, services, name, env, map[string]bool{})
+		if err != nil {
+			return err
+		}
+		services[name] = resolved
+	}
+
+	return nil
+}
+
+// notExtended are the keys a service never takes from the service it extends
+var notExtended = []string{"depends_on", "links", "volumes_from"}
+
+func extendService(dir string, services map[string]interface{}, name string, env map[string]string, visiting map[string]bool) (map[string]interface{}, error) {
+	service, ok := services[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("service %s is not defined in %s", name, dir)
+	}
+
+	extends, ok := service["extends"]
+	if !ok {
+		return service, nil
+	}
+
+	key := filepath.Join(dir, name)
+	if visiting[key] {
+		return nil, fmt.Errorf("service %s extends itself", name)
+	}
+	visiting[key] = true
+	defer delete(visiting, key)
+
+	var baseName, baseFile string
+	switch extends := extends.(type) {
+	case string:
+		baseName = extends
+	case map[string]interface{}:
+		baseName, _ = extends["service"].(string)
+		baseFile, _ = extends["file"].(string)
+	}
+	if baseName == "" {
+		return nil, fmt.Errorf("service %s must name the service it extends", name)
+	}
+
+	baseDir, baseServices := dir, services
+	if baseFile != "" {
+		path := filepath.Join(dir, baseFile)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read file extended by service %s: %v", name, err)
+		}
+
+		project, err := load(contents, env)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse %s: %v", path, err)
+		}
+		baseServices, _ = project["services"].(map[string]interface{})
+		baseDir = filepath.Dir(path)
+	}
+
+	base, err := extendService(baseDir, baseServices, baseName, env, visiting)
+	if err != nil {
+		return nil, err
+	}
+
+	extending := map[string]interface{}{}
+	for key, value := range service {
+		if key != "extends" {
+			extending[key] = value
+		}
+	}
+
+	extended := mergeService(base, extending)
+	for _, key := range notExtended {
+		if _, ok := service[key]; !ok {
+			delete(extended, key)
+		}
+	}
+
+	return extended, nil
+}
@@ -0,0 +1,170 @@
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	env := map[string]string{"SET": "value", "EMPTY": ""}
+
+	testTable := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "$SET and ${SET}", expected: "value and value"},
+		{input: "${UNSET:-default} ${EMPTY:-default}", expected: "default default"},
+		{input: "${UNSET-default} ${EMPTY-default}", expected: "default "},
+		{input: "$$SET costs $$5", expected: "$SET costs $5"},
+		{input: "$SET-suffix", expected: "value-suffix"},
+		{input: "${EMPTY?required}", expected: ""},
+		{input: "${EMPTY:?required}", expectError: true},
+		{input: "${UNSET?required}", expectError: true},
+		{input: "${SET", expectError: true},
+		{input: "${SET:x}", expectError: true},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := substitute(test.input, env)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("substituting should not have thrown an error: %v", err)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParsePort(t *testing.T) {
+	testTable := []struct {
+		input    string
+		expected []interface{}
+	}{
+		{
+			input:    "8080",
+			expected: []interface{}{map[string]interface{}{"target": 8080, "protocol": "tcp", "mode": "ingress"}},
+		},
+		{
+			input:    "80:8080",
+			expected: []interface{}{map[string]interface{}{"target": 8080, "published": 80, "protocol": "tcp", "mode": "ingress"}},
+		},
+		{
+			input: "127.0.0.1:5000-5001:6000-6001/udp",
+			expected: []interface{}{
+				map[string]interface{}{"target": 6000, "published": 5000, "host_ip": "127.0.0.1", "protocol": "udp", "mode": "ingress"},
+				map[string]interface{}{"target": 6001, "published": 5001, "host_ip": "127.0.0.1", "protocol": "udp", "mode": "ingress"},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := parsePort(test.input)
+			if err != nil {
+				t.Fatalf("parsing should not have thrown an error: %v", err)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParsePortErrors(t *testing.T) {
+	for _, input := range []string{"65536", "80:70000", "8080-8079", "1-65535", "0-1024", "http"} {
+		t.Run(input, func(t *testing.T) {
+			if actual, err := parsePort(input); err == nil {
+				t.Errorf("Expected an error, got %v", actual)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/docker-compose.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &Parser{Env: map[string]string{"WEB_PORT": "8000"}}
+	var input interface{}
+	if err := parser.UnmarshalFile("testdata/docker-compose.yml", contents, &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	web := map[string]interface{}{
+		"image":   "example/web:1.2",
+		"restart": "always",
+		"ports": []interface{}{
+			map[string]interface{}{"target": float64(8080), "published": float64(8000), "protocol": "tcp", "mode": "ingress"},
+			map[string]interface{}{"target": float64(9000), "published": float64(9000), "host_ip": "127.0.0.1", "protocol": "udp", "mode": "ingress"},
+			map[string]interface{}{"target": float64(9001), "published": float64(9001), "host_ip": "127.0.0.1", "protocol": "udp", "mode": "ingress"},
+		},
+		"volumes": []interface{}{
+			map[string]interface{}{"type": "volume", "source": "data", "target": "/var/lib/app"},
+			map[string]interface{}{"type": "bind", "source": "./src", "target": "/app/src", "read_only": true},
+		},
+		"environment": map[string]interface{}{"DEBUG": "true", "CONFTEST_COMPOSE_UNSET": nil},
+		"labels":      map[string]interface{}{"com.example.cost": "$5"},
+	}
+
+	worker := map[string]interface{}{}
+	for key, value := range web {
+		worker[key] = value
+	}
+	worker["command"] = "worker"
+
+	expected := map[string]interface{}{
+		"version": "3.7",
+		"services": map[string]interface{}{
+			"web":    web,
+			"worker": worker,
+		},
+	}
+
+	if !reflect.DeepEqual(input, expected) {
+		t.Errorf("Expected\n%v\nbut got\n%v", expected, input)
+	}
+}
+
+func TestParserStdin(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the .env and override files in testdata must not be read for stdin
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	contents := []byte(`services:
+  web:
+    image: "example/web:${TAG:-latest}"
+`)
+
+	var input interface{}
+	if err := new(Parser).UnmarshalFile("-", contents, &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{"image": "example/web:latest"},
+		},
+	}
+	if !reflect.DeepEqual(input, expected) {
+		t.Errorf("Expected\n%v\nbut got\n%v", expected, input)
+	}
+}
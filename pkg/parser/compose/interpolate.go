@@ -0,0 +1,129 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolate substitutes the variables in every string of a parsed compose
+// file
+func interpolate(value interface{}, env map[string]string) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		interpolated := map[string]interface{}{}
+		for key, item := range value {
+			result, err := interpolate(item, env)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[key] = result
+		}
+		return interpolated, nil
+	case []interface{}:
+		var interpolated []interface{}
+		for _, item := range value {
+			result, err := interpolate(item, env)
+			if err != nil {
+				return nil, err
+			}
+			interpolated = append(interpolated, result)
+		}
+		return interpolated, nil
+	case string:
+		return substitute(value, env)
+	default:
+		return value, nil
+	}
+}
+
+// substitute replaces $VAR and ${VAR} in s, supporting the ${VAR:-default},
+// ${VAR-default}, ${VAR:?error} and ${VAR?error} forms. $$ is a literal $.
+func substitute(s string, env map[string]string) (string, error) {
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			result.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			result.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("invalid interpolation format for %q", s)
+			}
+
+			value, err := expand(s[i+2:i+2+end], env)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(value)
+			i += 2 + end
+		case nameCharacter(next) && (next < '0' || next > '9'):
+			end := i + 2
+			for end < len(s) && nameCharacter(s[end]) {
+				end++
+			}
+			result.WriteString(env[s[i+1:end]])
+			i = end - 1
+		default:
+			return "", fmt.Errorf("invalid interpolation format for %q, use $$ for a literal $", s)
+		}
+	}
+
+	return result.String(), nil
+}
+
+func nameCharacter(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// expand returns the value of a braced variable expression
+func expand(expression string, env map[string]string) (string, error) {
+	name, operator, argument := expression, "", ""
+	if i := strings.IndexAny(expression, ":-?"); i >= 0 {
+		name = expression[:i]
+		for _, op := range []string{":-", ":?", "-", "?"} {
+			if strings.HasPrefix(expression[i:], op) {
+				operator = op
+				argument = expression[i+len(op):]
+				break
+			}
+		}
+		if operator == "" {
+			return "", fmt.Errorf("invalid interpolation format for ${%s}", expression)
+		}
+	}
+	if !variableName.MatchString(name) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expression)
+	}
+
+	value, set := env[name]
+	switch operator {
+	case ":-":
+		if value == "" {
+			return argument, nil
+		}
+	case "-":
+		if !set {
+			return argument, nil
+		}
+	case ":?":
+		if value == "" {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, argument)
+		}
+	case "?":
+		if !set {
+			return "", fmt.Errorf("required variable %s is missing a value: %s", name, argument)
+		}
+	}
+
+	return value, nil
+}
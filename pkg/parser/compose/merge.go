@@ -0,0 +1,167 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mergeProject merges an override file into a compose file. Services are
+// merged following docker-compose, other objects are merged recursively.
+func mergeProject(base, override map[string]interface{}) map[string]interface{} {
+	merged := copyMapping(base)
+	for key, value := range override {
+		if key != "services" {
+			merged[key] = mergeValue(merged[key], value)
+			continue
+		}
+
+		services := copyMapping(toMapping(merged["services"]))
+		for name, service := range toMapping(value) {
+			baseService, ok := services[name].(map[string]interface{})
+			overrideService, isService := service.(map[string]interface{})
+			if !ok || !isService {
+				services[name] = service
+				continue
+			}
+			services[name] = mergeService(baseService, overrideService)
+		}
+		merged["services"] = services
+	}
+
+	return merged
+}
+
+// mergeService merges the definition of a service into another. Lists of
+// ports and similar options are concatenated, volumes and devices replace
+// those mounted at the same path, environment and labels are merged by name,
+// and other options are replaced.
+func mergeService(base, override map[string]interface{}) map[string]interface{} {
+	merged := copyMapping(base)
+	for key, value := range override {
+		switch key {
+		case "ports", "expose", "external_links", "dns", "dns_search", "tmpfs":
+			merged[key] = appendUnique(toList(merged[key]), toList(value))
+		case "volumes", "devices":
+			merged[key] = mergeByTarget(toList(merged[key]), toList(value))
+		case "environment", "labels", "sysctls":
+			merged[key] = mergeValue(toMapping(merged[key]), toMapping(value))
+		default:
+			merged[key] = mergeValue(merged[key], value)
+		}
+	}
+
+	return merged
+}
+
+// mergeValue merges objects recursively, and otherwise returns override
+func mergeValue(base, override interface{}) interface{} {
+	baseObject, ok := base.(map[string]interface{})
+	overrideObject, isObject := override.(map[string]interface{})
+	if !ok || !isObject {
+		return override
+	}
+
+	merged := copyMapping(baseObject)
+	for key, value := range overrideObject {
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+func appendUnique(base, override []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	seen := map[string]bool{}
+	for _, item := range base {
+		seen[fmt.Sprint(item)] = true
+	}
+
+	for _, item := range override {
+		if !seen[fmt.Sprint(item)] {
+			seen[fmt.Sprint(item)] = true
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}
+
+// mergeByTarget replaces the mounts of base by those of override mounted at
+// the same path in the container
+func mergeByTarget(base, override []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	for _, mount := range override {
+		replaced := false
+		for i, existing := range merged {
+			if mountTarget(existing) == mountTarget(mount) {
+				merged[i] = mount
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, mount)
+		}
+	}
+
+	return merged
+}
+
+// mountTarget returns the path in the container of a volume or device in
+// either the short or long syntax
+func mountTarget(mount interface{}) string {
+	switch mount := mount.(type) {
+	case map[string]interface{}:
+		target, _ := mount["target"].(string)
+		return target
+	case string:
+		parts := strings.Split(mount, ":")
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		return parts[1]
+	default:
+		return fmt.Sprint(mount)
+	}
+}
+
+// toList returns a list option, which may also be given as a single value
+func toList(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return value
+	default:
+		return []interface{}{value}
+	}
+}
+
+// toMapping returns an option given either as an object or as a list of
+// name=value strings. Names in a list without a value are mapped to null.
+func toMapping(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return value
+	case []interface{}:
+		mapping := map[string]interface{}{}
+		for _, item := range value {
+			parts := strings.SplitN(fmt.Sprint(item), "=", 2)
+			if len(parts) == 1 {
+				mapping[parts[0]] = nil
+				continue
+			}
+			mapping[parts[0]] = parts[1]
+		}
+		return mapping
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func copyMapping(mapping map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{}
+	for key, value := range mapping {
+		c[key] = value
+	}
+	return c
+}
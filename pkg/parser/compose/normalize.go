@@ -0,0 +1,219 @@
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// normalizeServices converts the short syntax of the ports, volumes,
+// environment and labels of each service to the long syntax. Environment
+// variables without a value take their value from env when it is set.
+func normalizeServices(services map[string]interface{}, env map[string]string) error {
+	for name, value := range services {
+		service, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		normalized := copyMapping(service)
+		if ports, ok := service["ports"]; ok {
+			long, err := normalizePorts(toList(ports))
+			if err != nil {
+				return fmt.Errorf("service %s: %v", name, err)
+			}
+			normalized["ports"] = long
+		}
+		if volumes, ok := service["volumes"]; ok {
+			normalized["volumes"] = normalizeVolumes(toList(volumes))
+		}
+		if environment, ok := service["environment"]; ok {
+			normalized["environment"] = normalizeEnvironment(toMapping(environment), env)
+		}
+		if labels, ok := service["labels"]; ok {
+			normalized["labels"] = toMapping(labels)
+		}
+		services[name] = normalized
+	}
+
+	return nil
+}
+
+// normalizePorts converts ports given as [host_ip:][published:]target[/protocol]
+// to objects. Port ranges are expanded to an object per port.
+func normalizePorts(ports []interface{}) ([]interface{}, error) {
+	normalized := []interface{}{}
+	for _, port := range ports {
+		switch port := port.(type) {
+		case map[string]interface{}:
+			normalized = append(normalized, port)
+		case float64:
+			normalized = append(normalized, map[string]interface{}{"target": int(port), "protocol": "tcp", "mode": "ingress"})
+		default:
+			long, err := parsePort(fmt.Sprint(port))
+			if err != nil {
+				return nil, err
+			}
+			normalized = append(normalized, long...)
+		}
+	}
+
+	return normalized, nil
+}
+
+func parsePort(spec string) ([]interface{}, error) {
+	protocol := "tcp"
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		protocol = spec[i+1:]
+		spec = spec[:i]
+	}
+
+	var hostIP, published, target string
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		target = parts[0]
+	case 2:
+		published, target = parts[0], parts[1]
+	default:
+		hostIP = strings.Join(parts[:len(parts)-2], ":")
+		published, target = parts[len(parts)-2], parts[len(parts)-1]
+	}
+
+	targets, err := portRange(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %v", spec, err)
+	}
+	var publishedPorts []int
+	if published != "" {
+		publishedPorts, err = portRange(published)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %v", spec, err)
+		}
+		if len(publishedPorts) != len(targets) && len(publishedPorts) != 1 {
+			return nil, fmt.Errorf("invalid port %q: the published and target ranges differ in size", spec)
+		}
+	}
+
+	var long []interface{}
+	for i, port := range targets {
+		mapping := map[string]interface{}{"target": port, "protocol": protocol, "mode": "ingress"}
+		if len(publishedPorts) == 1 {
+			mapping["published"] = publishedPorts[0]
+		} else if len(publishedPorts) > 0 {
+			mapping["published"] = publishedPorts[i]
+		}
+		if hostIP != "" {
+			mapping["host_ip"] = hostIP
+		}
+		long = append(long, mapping)
+	}
+
+	return long, nil
+}
+
+// maxPort is the largest TCP and UDP port number
+const maxPort = 65535
+
+// maxPortRange is the largest number of ports a range may expand to, so that
+// a mistyped range doesn't turn into tens of thousands of mappings
+const maxPortRange = 1024
+
+// portRange returns the ports of a single port or of a start-end range
+func portRange(spec string) ([]int, error) {
+	bounds := strings.SplitN(spec, "-", 2)
+	start, err := portNumber(bounds[0])
+	if err != nil {
+		return nil, err
+	}
+	end := start
+	if len(bounds) == 2 {
+		end, err = portNumber(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if end < start {
+		return nil, fmt.Errorf("range %s ends before it starts", spec)
+	}
+	if end-start >= maxPortRange {
+		return nil, fmt.Errorf("range %s has more than %d ports", spec, maxPortRange)
+	}
+
+	var ports []int
+	for port := start; port <= end; port++ {
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// portNumber parses a port, which must be between 0 and 65535
+func portNumber(spec string) (int, error) {
+	port, err := strconv.Atoi(spec)
+	if err != nil {
+		return 0, err
+	}
+	if port < 0 || port > maxPort {
+		return 0, fmt.Errorf("port %d is not between 0 and %d", port, maxPort)
+	}
+	return port, nil
+}
+
+// normalizeVolumes converts volumes given as [source:]target[:mode] to
+// objects. Sources that are paths are bind mounts, others are named volumes.
+func normalizeVolumes(volumes []interface{}) []interface{} {
+	normalized := []interface{}{}
+	for _, volume := range volumes {
+		spec, ok := volume.(string)
+		if !ok {
+			normalized = append(normalized, volume)
+			continue
+		}
+
+		parts := strings.Split(spec, ":")
+		if len(parts) == 1 {
+			normalized = append(normalized, map[string]interface{}{"type": "volume", "target": parts[0]})
+			continue
+		}
+
+		mount := map[string]interface{}{"type": "volume", "source": parts[0], "target": parts[1]}
+		if strings.HasPrefix(parts[0], ".") || strings.HasPrefix(parts[0], "/") || strings.HasPrefix(parts[0], "~") {
+			mount["type"] = "bind"
+		}
+		if len(parts) > 2 {
+			for _, option := range strings.Split(parts[2], ",") {
+				switch option {
+				case "ro":
+					mount["read_only"] = true
+				case "nocopy":
+					mount["volume"] = map[string]interface{}{"nocopy": true}
+				case "cached", "delegated", "consistent":
+					mount["consistency"] = option
+				}
+			}
+		}
+		normalized = append(normalized, mount)
+	}
+
+	return normalized
+}
+
+func normalizeEnvironment(environment map[string]interface{}, env map[string]string) map[string]interface{} {
+	normalized := map[string]interface{}{}
+	for name, value := range environment {
+		switch value := value.(type) {
+		case nil:
+			if envValue, ok := env[name]; ok {
+				normalized[name] = envValue
+			} else {
+				normalized[name] = nil
+			}
+		case string:
+			normalized[name] = value
+		default:
+			normalized[name] = fmt.Sprint(value)
+		}
+	}
+
+	return normalized
+}
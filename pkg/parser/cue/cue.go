@@ -2,14 +2,26 @@ package cue
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"cuelang.org/go/cue"
 	cFormat "cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/load"
 	"github.com/ghodss/yaml"
 )
 
+// Parser evaluates CUE and exports the concrete result. Files read from disk
+// are loaded together with the other files of their package, so that
+// templates defined elsewhere in the package and imports are applied, and
+// each package is reported once.
 type Parser struct{}
 
+var packageClause = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z_][A-Za-z0-9_]*)`)
+
 func (c *Parser) Unmarshal(p []byte, v interface{}) error {
 	var r cue.Runtime
 	out, err := cFormat.Source(p)
@@ -20,7 +32,98 @@ func (c *Parser) Unmarshal(p []byte, v interface{}) error {
 	if err != nil {
 		return fmt.Errorf("error occured parsing cue: %v", err)
 	}
-	j, err := instance.Value().MarshalJSON()
+
+	return export(instance.Value(), v)
+}
+
+// Instance names the CUE instance the file at path is evaluated in. Files
+// declaring a package share the instance of that package in their directory,
+// files without a package clause are an instance of their own.
+func (c *Parser) Instance(path string, p []byte) string {
+	pkg := packageName(p)
+	if path == "" || path == "-" || pkg == "" {
+		return path
+	}
+
+	return filepath.Dir(path) + ":" + pkg
+}
+
+// UnmarshalFile loads the CUE instance the file at path belongs to. Files
+// declaring a package are evaluated with the other files of that package in
+// their directory, files without a package clause are evaluated on their own.
+// In both cases imports are resolved.
+func (c *Parser) UnmarshalFile(path string, p []byte, v interface{}) error {
+	if path == "" || path == "-" {
+		return c.Unmarshal(p, v)
+	}
+
+	dir := filepath.Dir(path)
+	files := []string{filepath.Base(path)}
+	if pkg := packageName(p); pkg != "" {
+		var err error
+		files, err = packageFiles(dir, pkg)
+		if err != nil {
+			return fmt.Errorf("Unable to list the files of cue package %s: %v", pkg, err)
+		}
+	}
+
+	instances := load.Instances(files, &load.Config{Dir: dir})
+	if len(instances) != 1 {
+		return fmt.Errorf("expected a single cue instance in %s, found %d", dir, len(instances))
+	}
+	if instances[0].Err != nil {
+		return fmt.Errorf("Unable to load cue instance: %v", instances[0].Err)
+	}
+
+	instance := cue.Build(instances)[0]
+	if instance.Err != nil {
+		return fmt.Errorf("Unable to build cue instance: %v", instance.Err)
+	}
+
+	return export(instance.Value(), v)
+}
+
+func packageName(p []byte) string {
+	match := packageClause.FindSubmatch(p)
+	if match == nil {
+		return ""
+	}
+
+	return string(match[1])
+}
+
+// packageFiles lists the CUE files in dir that declare pkg, so that other
+// packages in the same directory are left out
+func packageFiles(dir string, pkg string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".cue" {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if packageName(contents) == pkg {
+			files = append(files, info.Name())
+		}
+	}
+
+	return files, nil
+}
+
+func export(value cue.Value, v interface{}) error {
+	if problems := incomplete(value, nil); len(problems) > 0 {
+		return fmt.Errorf("cue value is not concrete:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	j, err := value.MarshalJSON()
 	if err != nil {
 		return fmt.Errorf("Unable to marshal cue config: %s", err)
 	}
@@ -30,3 +133,56 @@ func (c *Parser) Unmarshal(p []byte, v interface{}) error {
 	}
 	return nil
 }
+
+// incomplete lists the values that are not concrete, and so cannot be
+// exported, with their path and position
+func incomplete(value cue.Value, path []string) []string {
+	switch value.Kind() {
+	case cue.StructKind:
+		fields, err := value.Fields()
+		if err != nil {
+			return []string{problem(value, path, err)}
+		}
+
+		var problems []string
+		for fields.Next() {
+			problems = append(problems, incomplete(fields.Value(), child(path, fields.Label()))...)
+		}
+		return problems
+	case cue.ListKind:
+		items, err := value.List()
+		if err != nil {
+			return []string{problem(value, path, err)}
+		}
+
+		var problems []string
+		for i := 0; items.Next(); i++ {
+			problems = append(problems, incomplete(items.Value(), child(path, strconv.Itoa(i)))...)
+		}
+		return problems
+	case cue.BottomKind:
+		return []string{problem(value, path, value.Err())}
+	}
+
+	return nil
+}
+
+func problem(value cue.Value, path []string, err error) string {
+	name := strings.Join(path, ".")
+	if name == "" {
+		name = "<root>"
+	}
+
+	message := "incomplete value"
+	if err != nil {
+		message = err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s (%v)", name, message, value.Pos())
+}
+
+func child(path []string, label string) []string {
+	c := make([]string, len(path), len(path)+1)
+	copy(c, path)
+	return append(c, label)
+}
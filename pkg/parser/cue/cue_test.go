@@ -1,6 +1,11 @@
 package cue
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -37,3 +42,85 @@ func TestCueParser(t *testing.T) {
 		t.Error("There should be at least one item defined in the parsed file, but none found")
 	}
 }
+
+func TestCueParserPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"deployment.cue": `package kubernetes
+
+deployment "hello": {
+	spec replicas: 3
+}
+`,
+		"templates.cue": `package kubernetes
+
+import "strings"
+
+deployment <Name>: {
+	kind: "Deployment"
+	metadata name: strings.ToUpper(Name)
+	spec replicas: int
+}
+`,
+		"service.cue": `package service
+
+name: "hello"
+`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "deployment.cue")
+	var input interface{}
+	if err := new(Parser).UnmarshalFile(path, []byte(files["deployment.cue"]), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"deployment": map[string]interface{}{
+			"hello": map[string]interface{}{
+				"kind":     "Deployment",
+				"metadata": map[string]interface{}{"name": "HELLO"},
+				"spec":     map[string]interface{}{"replicas": float64(3)},
+			},
+		},
+	}
+	if !reflect.DeepEqual(input, expected) {
+		t.Errorf("Expected\n%v\nbut got\n%v", expected, input)
+	}
+
+	parser := new(Parser)
+	deployment := parser.Instance(path, []byte(files["deployment.cue"]))
+	templates := parser.Instance(filepath.Join(dir, "templates.cue"), []byte(files["templates.cue"]))
+	service := parser.Instance(filepath.Join(dir, "service.cue"), []byte(files["service.cue"]))
+	if deployment != templates {
+		t.Errorf("Expected files of the same package to share an instance, got %s and %s", deployment, templates)
+	}
+	if deployment == service {
+		t.Errorf("Expected files of another package to have their own instance, got %s", service)
+	}
+}
+
+func TestCueParserIncomplete(t *testing.T) {
+	p := `deployment "hello": {
+	spec replicas: int
+}`
+
+	var input interface{}
+	err := new(Parser).Unmarshal([]byte(p), &input)
+	if err == nil {
+		t.Fatal("parser should have thrown an error for an incomplete value")
+	}
+
+	if !strings.Contains(err.Error(), "deployment.hello.spec.replicas") {
+		t.Errorf("Expected the error to name the incomplete value, got %v", err)
+	}
+}
@@ -9,7 +9,11 @@ import (
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 )
 
-type Parser struct{}
+// Parser parses Dockerfiles into a flat list of commands. When Stages is set
+// the commands are also grouped into build stages, see Dockerfile.
+type Parser struct {
+	Stages bool
+}
 
 type Command struct {
 	Cmd    string   // lowercased command name (ex: `from`)
@@ -48,7 +52,15 @@ func (dp *Parser) Unmarshal(p []byte, v interface{}) error {
 		ret = append(ret, cmd)
 	}
 
-	j, err := json.Marshal(ret)
+	var document interface{} = ret
+	if dp.Stages {
+		document, err = buildStages(res, ret, p)
+		if err != nil {
+			return fmt.Errorf("Unable to resolve Dockerfile stages: %s", err)
+		}
+	}
+
+	j, err := json.Marshal(document)
 	if err != nil {
 		return fmt.Errorf("Unable to marshal config: %s", err)
 	}
@@ -1,6 +1,8 @@
 package docker_test
 
 import (
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -31,3 +33,81 @@ func TestParser_Unmarshal(t *testing.T) {
 		t.Error("The first command should be from")
 	}
 }
+
+func TestParser_UnmarshalStages(t *testing.T) {
+	parser := &docker.Parser{Stages: true}
+
+	sample := `ARG GO_VERSION=1.12
+FROM golang:${GO_VERSION}-alpine AS builder
+ARG GO_VERSION
+ENV CGO_ENABLED=0 APP=conftest
+WORKDIR /src/${APP}
+RUN apk add --no-cache git && \
+    go build -o /bin/${APP} ./cmd
+
+FROM builder AS test
+USER nobody
+RUN go test ./...
+
+FROM --platform=linux/amd64 alpine:3.9
+COPY --from=builder /bin/${APP} /bin/
+USER app
+`
+
+	var input interface{}
+	if err := parser.Unmarshal([]byte(sample), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	var dockerfile docker.Dockerfile
+	j, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(j, &dockerfile); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dockerfile.Commands) != 12 {
+		t.Errorf("Expected the flat list of %d commands, got %d", 12, len(dockerfile.Commands))
+	}
+	if dockerfile.Args["GO_VERSION"] != "1.12" {
+		t.Errorf("Expected %v, got %v", "1.12", dockerfile.Args["GO_VERSION"])
+	}
+	if len(dockerfile.Stages) != 3 {
+		t.Fatalf("Expected %d stages, got %d", 3, len(dockerfile.Stages))
+	}
+
+	builder := dockerfile.Stages[0]
+	if builder.Name != "builder" || builder.From.Image != "golang:1.12-alpine" {
+		t.Errorf("Expected stage builder from golang:1.12-alpine, got %v from %v", builder.Name, builder.From.Image)
+	}
+	if builder.WorkDir != "/src/conftest" {
+		t.Errorf("Expected %v, got %v", "/src/conftest", builder.WorkDir)
+	}
+	if builder.Args["GO_VERSION"] != "1.12" || builder.Env["CGO_ENABLED"] != "0" {
+		t.Errorf("Expected the stage variables to be resolved, got %v and %v", builder.Args, builder.Env)
+	}
+
+	run := builder.Commands[4]
+	if run.Cmd != "run" || run.StartLine != 6 || run.EndLine != 7 {
+		t.Errorf("Expected RUN on lines 6 to 7, got %v on lines %d to %d", run.Cmd, run.StartLine, run.EndLine)
+	}
+	expectedWords := [][]string{{"apk", "add", "--no-cache", "git"}, {"go", "build", "-o", "/bin/${APP}", "./cmd"}}
+	if !reflect.DeepEqual(run.Words, expectedWords) {
+		t.Errorf("Expected %v, got %v", expectedWords, run.Words)
+	}
+
+	test := dockerfile.Stages[1]
+	if test.From.Stage != "builder" || test.User != "nobody" || test.Env["APP"] != "conftest" {
+		t.Errorf("Expected stage test to build on builder as nobody, got %+v", test)
+	}
+
+	final := dockerfile.Stages[2]
+	if final.From.Image != "alpine:3.9" || final.From.Platform != "linux/amd64" || final.User != "app" {
+		t.Errorf("Expected the final stage to be alpine:3.9 on linux/amd64 as app, got %+v", final)
+	}
+	if instruction := final.Commands[1]; !reflect.DeepEqual(instruction.Resolved, []string{"/bin/", "/bin/"}) {
+		t.Errorf("Expected variables from other stages not to be resolved, got %v", instruction.Resolved)
+	}
+}
@@ -0,0 +1,324 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// Dockerfile groups the instructions of a Dockerfile into build stages. The
+// flat list of commands is kept in Commands.
+type Dockerfile struct {
+	Args     map[string]string // ARGs declared before the first FROM
+	Stages   []Stage
+	Commands []Command
+}
+
+// Stage is a build stage, starting at a FROM instruction
+type Stage struct {
+	Index    int
+	Name     string // the name given with FROM ... AS name
+	From     From
+	User     string // the USER the stage runs as when it ends
+	WorkDir  string
+	Args     map[string]string
+	Env      map[string]string
+	Commands []Instruction
+}
+
+// From is the base of a stage. Stage is set when the base is an earlier stage
+// of the same Dockerfile.
+type From struct {
+	Image    string
+	Platform string
+	Stage    string
+}
+
+// Instruction is a command with its arguments resolved and its position in
+// the Dockerfile
+type Instruction struct {
+	Command
+	Resolved  []string   // Value with ARG and ENV variables substituted
+	Words     [][]string // for RUN, the shell words of each command it runs
+	StartLine int
+	EndLine   int
+}
+
+// substituted are the instructions Docker substitutes variables in
+var substituted = map[string]bool{
+	"add":        true,
+	"arg":        true,
+	"copy":       true,
+	"env":        true,
+	"expose":     true,
+	"from":       true,
+	"label":      true,
+	"stopsignal": true,
+	"user":       true,
+	"volume":     true,
+	"workdir":    true,
+}
+
+type variables struct {
+	names  []string
+	values map[string]string
+}
+
+func newVariables() *variables {
+	return &variables{values: map[string]string{}}
+}
+
+func (v *variables) set(name, value string) {
+	if _, ok := v.values[name]; !ok {
+		v.names = append(v.names, name)
+	}
+	v.values[name] = value
+}
+
+func (v *variables) copy() *variables {
+	c := newVariables()
+	for _, name := range v.names {
+		c.set(name, v.values[name])
+	}
+	return c
+}
+
+func (v *variables) environ() []string {
+	var env []string
+	for _, name := range v.names {
+		env = append(env, name+"="+v.values[name])
+	}
+	return env
+}
+
+// buildStages resolves the stages of a parsed Dockerfile. Variables are
+// substituted following Docker: ARGs declared before the first FROM are
+// available to FROM lines, and to stages that declare them again, while ENV
+// takes precedence over ARG and is inherited by stages built on a stage.
+func buildStages(result *parser.Result, commands []Command, source []byte) (Dockerfile, error) {
+	lex := shell.NewLex(result.EscapeToken)
+	lines := strings.Split(string(source), "\n")
+	dockerfile := Dockerfile{Args: map[string]string{}, Stages: []Stage{}, Commands: commands}
+
+	globalArgs := newVariables()
+	var stage *Stage
+	var args, env *variables
+	stageEnv := map[string]*variables{}
+
+	for i, child := range result.AST.Children {
+		instruction := Instruction{
+			Command:   commands[i],
+			StartLine: child.StartLine,
+			EndLine:   endLine(lines, child.StartLine, result.EscapeToken),
+		}
+
+		environ := globalArgs.environ()
+		if stage != nil && instruction.Cmd != "from" {
+			environ = append(env.environ(), args.environ()...)
+		}
+
+		instruction.Resolved = instruction.Value
+		if substituted[instruction.Cmd] && instruction.SubCmd == "" {
+			resolved, err := resolve(lex, instruction.Value, environ)
+			if err != nil {
+				return Dockerfile{}, fmt.Errorf("line %d: %v", child.StartLine, err)
+			}
+			instruction.Resolved = resolved
+		}
+
+		switch instruction.Cmd {
+		case "from":
+			from, name := parseFrom(instruction)
+			dockerfile.Stages = append(dockerfile.Stages, Stage{
+				Index: len(dockerfile.Stages),
+				Name:  name,
+				From:  from,
+			})
+			stage = &dockerfile.Stages[len(dockerfile.Stages)-1]
+			args = newVariables()
+			env = newVariables()
+
+			for j := range dockerfile.Stages[:stage.Index] {
+				parent := dockerfile.Stages[j]
+				if parent.Name != "" && strings.EqualFold(parent.Name, from.Image) {
+					stage.From.Stage = parent.Name
+					stage.User = parent.User
+					stage.WorkDir = parent.WorkDir
+					env = stageEnv[parent.Name].copy()
+				}
+			}
+		case "arg":
+			for _, arg := range instruction.Resolved {
+				parts := strings.SplitN(arg, "=", 2)
+				name := parts[0]
+				var value string
+				if len(parts) == 2 {
+					value = parts[1]
+				} else if stage != nil {
+					value = globalArgs.values[name]
+				}
+
+				if stage == nil {
+					globalArgs.set(name, value)
+					dockerfile.Args[name] = value
+					continue
+				}
+				args.set(name, value)
+			}
+		case "env":
+			for j := 0; j+1 < len(instruction.Resolved); j += 2 {
+				if env != nil {
+					env.set(instruction.Resolved[j], instruction.Resolved[j+1])
+				}
+			}
+		case "user":
+			if stage != nil && len(instruction.Resolved) > 0 {
+				stage.User = instruction.Resolved[0]
+			}
+		case "workdir":
+			if stage != nil && len(instruction.Resolved) > 0 {
+				stage.WorkDir = instruction.Resolved[0]
+			}
+		case "run":
+			if instruction.JSON {
+				instruction.Words = [][]string{instruction.Value}
+			} else {
+				instruction.Words = shellWords(strings.Join(instruction.Value, " "))
+			}
+		}
+
+		if stage == nil {
+			continue
+		}
+
+		stage.Commands = append(stage.Commands, instruction)
+		stage.Args = args.values
+		stage.Env = env.values
+		if stage.Name != "" {
+			stageEnv[stage.Name] = env
+		}
+	}
+
+	return dockerfile, nil
+}
+
+func resolve(lex *shell.Lex, values []string, environ []string) ([]string, error) {
+	var resolved []string
+	for _, value := range values {
+		word, err := lex.ProcessWord(value, environ)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, word)
+	}
+
+	return resolved, nil
+}
+
+// parseFrom returns the base of a stage and the name given to it, from the
+// resolved arguments of a FROM instruction
+func parseFrom(instruction Instruction) (From, string) {
+	var from From
+	for _, flag := range instruction.Flags {
+		if strings.HasPrefix(flag, "--platform=") {
+			from.Platform = strings.TrimPrefix(flag, "--platform=")
+		}
+	}
+
+	var name string
+	if len(instruction.Resolved) > 0 {
+		from.Image = instruction.Resolved[0]
+	}
+	if len(instruction.Resolved) == 3 && strings.EqualFold(instruction.Resolved[1], "as") {
+		name = instruction.Resolved[2]
+	}
+
+	return from, name
+}
+
+// shellWords splits a shell command line into the words of each command it
+// runs. Commands are separated by &&, ||, ;, | and &. Quotes and escapes are
+// removed, variables are left as they are.
+func shellWords(line string) [][]string {
+	var commands [][]string
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				word.WriteRune(runes[i])
+			}
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+		case r == ';' || r == '&' || r == '|' || r == '\n':
+			endCommand()
+			if i+1 < len(runes) && (r == '&' || r == '|') && runes[i+1] == r {
+				i++
+			}
+		case r == ' ' || r == '\t':
+			endWord()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+
+	return commands
+}
+
+// endLine returns the last line of the instruction starting on line start,
+// following line continuations over comments and empty lines
+func endLine(lines []string, start int, escapeToken rune) int {
+	end := start
+	for end < len(lines) && continues(lines[end-1], escapeToken) {
+		end++
+		for end < len(lines) && ignored(lines[end-1]) {
+			end++
+		}
+	}
+
+	return end
+}
+
+func continues(line string, escapeToken rune) bool {
+	return strings.HasSuffix(strings.TrimRight(line, " \t\r"), string(escapeToken))
+}
+
+func ignored(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
@@ -0,0 +1,128 @@
+package dotenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Parser parses dotenv files into a map of variable names to string values.
+// Variables are not expanded.
+type Parser struct{}
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func (d *Parser) Unmarshal(p []byte, v interface{}) error {
+	variables, err := parse(string(p))
+	if err != nil {
+		return fmt.Errorf("Unable to parse dotenv file: %v", err)
+	}
+
+	j, err := json.Marshal(variables)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse dotenv to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from dotenv-json: %s", err)
+	}
+
+	return nil
+}
+
+func parse(contents string) (map[string]string, error) {
+	variables := map[string]string{}
+
+	lines := strings.Split(strings.Replace(contents, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimSpace(line[len("export"):])
+		}
+
+		separator := strings.Index(line, "=")
+		if separator < 0 {
+			return nil, fmt.Errorf("line %d: expected NAME=value", number)
+		}
+
+		name := strings.TrimSpace(line[:separator])
+		if !variableName.MatchString(name) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", number, name)
+		}
+
+		value := strings.TrimLeft(line[separator+1:], " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			variables[name] = unquoted(value)
+			continue
+		}
+
+		// quoted values may span several lines
+		quote := value[0]
+		value = value[1:]
+		for {
+			end := closingQuote(value, quote)
+			if end >= 0 {
+				rest := strings.TrimSpace(value[end+1:])
+				if rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, fmt.Errorf("line %d: unexpected characters after quoted value", number)
+				}
+				value = value[:end]
+				break
+			}
+
+			i++
+			if i == len(lines) {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", number)
+			}
+			value += "\n" + lines[i]
+		}
+
+		if quote == '"' {
+			value = unescape(value)
+		}
+		variables[name] = value
+	}
+
+	return variables, nil
+}
+
+// unquoted returns a value without quotes, dropping any comment after it
+func unquoted(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, "\t#"); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// closingQuote returns the index of the quote ending a value, skipping quotes
+// escaped with a backslash in double quoted values
+func closingQuote(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func unescape(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,69 @@
+package dotenv_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/parser/dotenv"
+)
+
+func TestDotenvParser(t *testing.T) {
+	testTable := []struct {
+		name           string
+		controlConfigs []byte
+		expectedResult interface{}
+		shouldError    bool
+	}{
+		{
+			name: "variables",
+			controlConfigs: []byte(`# database settings
+DATABASE_URL=postgres://localhost/app
+export SECRET_KEY = changeme # rotate this
+EMPTY=
+SINGLE='literal $HOME \n'
+DOUBLE="line one\nline \"two\""
+MULTILINE="first
+second"
+`),
+			expectedResult: map[string]interface{}{
+				"DATABASE_URL": "postgres://localhost/app",
+				"SECRET_KEY":   "changeme",
+				"EMPTY":        "",
+				"SINGLE":       `literal $HOME \n`,
+				"DOUBLE":       "line one\nline \"two\"",
+				"MULTILINE":    "first\nsecond",
+			},
+		},
+		{
+			name:           "missing separator",
+			controlConfigs: []byte("DATABASE_URL\n"),
+			shouldError:    true,
+		},
+		{
+			name:           "unterminated quote",
+			controlConfigs: []byte("SECRET=\"changeme\n"),
+			shouldError:    true,
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			var input interface{}
+			parser := new(dotenv.Parser)
+			err := parser.Unmarshal(test.controlConfigs, &input)
+			if test.shouldError {
+				if err == nil {
+					t.Error("we expected an error but did not see one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parser should not have thrown an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedResult, input) {
+				t.Errorf("Expected\n%v\nbut got\n%v", test.expectedResult, input)
+			}
+		})
+	}
+}
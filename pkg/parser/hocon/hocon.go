@@ -0,0 +1,158 @@
+package hocon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-akka/configuration"
+	"github.com/go-akka/configuration/hocon"
+)
+
+// Parser parses HOCON files, as used by Akka and Play, resolving includes
+// relative to the file and substitutions. The HOCON library does not keep
+// whether a value was quoted, so true and false become booleans and values
+// that look like numbers become numbers, everything else is a string.
+type Parser struct{}
+
+func (h *Parser) Unmarshal(p []byte, v interface{}) error {
+	return h.UnmarshalFile("", p, v)
+}
+
+// UnmarshalFile parses the HOCON read from path
+func (h *Parser) UnmarshalFile(path string, p []byte, v interface{}) (err error) {
+	// the HOCON library panics on invalid input
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Unable to parse HOCON: %v", r)
+		}
+	}()
+
+	dir := "."
+	if path != "" && path != "-" {
+		dir = filepath.Dir(path)
+	}
+
+	includes := &includer{including: map[string]bool{}}
+	if path != "" && path != "-" {
+		if abs, err := filepath.Abs(path); err == nil {
+			includes.including[abs] = true
+		}
+	}
+	config := configuration.ParseString(string(p), includes.callback(dir))
+	if includes.err != nil {
+		return includes.err
+	}
+	if config == nil || config.Root() == nil {
+		return fmt.Errorf("Unable to parse HOCON: empty document")
+	}
+
+	value, err := toValue(config.Root())
+	if err != nil {
+		return fmt.Errorf("Unable to parse HOCON: %v", err)
+	}
+
+	j, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse hocon to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from hocon-json: %s", err)
+	}
+
+	return nil
+}
+
+// includer resolves include statements. The HOCON library has no way for an
+// include to fail, so the first error is kept and the include is left empty.
+// The files being included are tracked, as a cycle of includes would
+// otherwise recurse until the stack overflows.
+type includer struct {
+	err       error
+	including map[string]bool
+}
+
+// callback resolves includes relative to dir. Included files resolve their
+// own includes relative to themselves.
+func (i *includer) callback(dir string) hocon.IncludeCallback {
+	return func(filename string) *hocon.HoconRoot {
+		if i.err != nil {
+			return hocon.Parse("", nil)
+		}
+
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			i.err = fmt.Errorf("Unable to include %s: %v", filename, err)
+			return hocon.Parse("", nil)
+		}
+		if i.including[abs] {
+			i.err = fmt.Errorf("Unable to include %s: cyclic include", filename)
+			return hocon.Parse("", nil)
+		}
+
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			i.err = fmt.Errorf("Unable to include %s: %v", filename, err)
+			return hocon.Parse("", nil)
+		}
+
+		i.including[abs] = true
+		defer delete(i.including, abs)
+
+		return hocon.Parse(string(contents), i.callback(filepath.Dir(filename)))
+	}
+}
+
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+func toValue(value *hocon.HoconValue) (interface{}, error) {
+	switch {
+	case value == nil:
+		return nil, nil
+	case value.IsObject():
+		object := map[string]interface{}{}
+		for key, item := range value.GetObject().Items() {
+			v, err := toValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			object[key] = v
+		}
+		return object, nil
+	case value.IsArray():
+		array := []interface{}{}
+		for _, item := range value.GetArray() {
+			v, err := toValue(item)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+		return array, nil
+	case value.IsEmpty():
+		return nil, fmt.Errorf("missing value")
+	default:
+		return scalar(value.GetString()), nil
+	}
+}
+
+func scalar(s string) interface{} {
+	switch {
+	case s == "true":
+		return true
+	case s == "false":
+		return false
+	case number.MatchString(s):
+		return json.Number(s)
+	default:
+		return s
+	}
+}
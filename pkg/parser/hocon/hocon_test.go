@@ -0,0 +1,103 @@
+package hocon_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/parser/hocon"
+)
+
+func TestHOCONParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaults := `akka {
+  loglevel = "INFO"
+  remote.artery.enabled = true
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "defaults.conf"), []byte(defaults), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sample := `include "defaults.conf"
+
+play.http.secret.key = "changeme"
+akka {
+  loglevel = "DEBUG"
+  remote.artery.canonical.port = 25520
+  cluster.seed-nodes = ["akka://app@host1:2552", "akka://app@host2:2552"]
+}
+db.default.url = ${play.http.secret.key}
+`
+
+	var input interface{}
+	parser := new(hocon.Parser)
+	if err := parser.UnmarshalFile(filepath.Join(dir, "application.conf"), []byte(sample), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"akka": map[string]interface{}{
+			"loglevel": "DEBUG",
+			"remote": map[string]interface{}{
+				"artery": map[string]interface{}{
+					"enabled":   true,
+					"canonical": map[string]interface{}{"port": 25520.0},
+				},
+			},
+			"cluster": map[string]interface{}{
+				"seed-nodes": []interface{}{"akka://app@host1:2552", "akka://app@host2:2552"},
+			},
+		},
+		"play": map[string]interface{}{
+			"http": map[string]interface{}{
+				"secret": map[string]interface{}{"key": "changeme"},
+			},
+		},
+		"db": map[string]interface{}{
+			"default": map[string]interface{}{"url": "changeme"},
+		},
+	}
+	if !reflect.DeepEqual(expected, input) {
+		t.Errorf("Expected\n%v\nbut got\n%v", expected, input)
+	}
+
+	if err := parser.Unmarshal([]byte(`akka { loglevel = `), &input); err == nil {
+		t.Error("we expected an error for an incomplete document")
+	}
+
+	missing := `include "missing.conf"
+akka.loglevel = "DEBUG"
+`
+	if err := parser.UnmarshalFile(filepath.Join(dir, "application.conf"), []byte(missing), &input); err == nil {
+		t.Error("we expected an error for a missing include")
+	}
+
+	// a file including itself through another must not recurse forever
+	cycle := map[string]string{
+		"application.conf": `include "cluster.conf"
+akka.loglevel = "DEBUG"
+`,
+		"cluster.conf": `include "application.conf"
+akka.cluster.min-nr-of-members = 2
+`,
+	}
+	for name, contents := range cycle {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := parser.UnmarshalFile(filepath.Join(dir, "application.conf"), []byte(cycle["application.conf"]), &input); err == nil {
+		t.Error("we expected an error for a cyclic include")
+	}
+	if err := parser.Unmarshal([]byte(`include "`+filepath.Join(dir, "cluster.conf")+`"`), &input); err == nil {
+		t.Error("we expected an error for a cyclic include read from stdin")
+	}
+}
@@ -3,29 +3,64 @@ package ini
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/ghodss/yaml"
 	"github.com/go-ini/ini"
 )
 
-type Parser struct{}
+// Parser parses INI files into an object of sections. Keys outside of any
+// section are found in the DEFAULT section, repeated keys become arrays and
+// sections named with dots, such as [a.b], are also nested into objects. When
+// InferTypes is set, numbers are converted from strings, as are true and false
+// in any case. When Comments is set, the comments of each section and of its
+// keys are kept under the reserved __comments__ key of the section.
+type Parser struct {
+	InferTypes bool
+	Comments   bool
+}
+
+// CommentsKey is the key of a section holding its comments when they are
+// kept. The comment of the section itself is found under section, and those
+// of its keys under keys.
+const CommentsKey = "__comments__"
+
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
 
 func (i *Parser) Unmarshal(p []byte, v interface{}) error {
-	result := map[string]map[string]string{}
-	cfg, err := ini.Load(p)
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, p)
 	if err != nil {
 		return fmt.Errorf("Fail to read ini file: %v", err)
 	}
 
-	sections := cfg.Sections()
-	for _, s := range sections {
+	result := map[string]interface{}{}
+	sections := map[string]map[string]interface{}{}
+	var names []string
+	for _, s := range cfg.Sections() {
 		sectionName := s.Name()
-		if sectionName == "DEFAULT" {
+		values := i.sectionValues(s)
+		if sectionName == ini.DEFAULT_SECTION && len(values) == 0 {
 			continue
 		}
-		result[sectionName] = map[string]string{}
-		result[sectionName] = s.KeysHash()
+
+		sections[sectionName] = values
+		names = append(names, sectionName)
+	}
+
+	// parents are nested before their subsections
+	sort.SliceStable(names, func(a, b int) bool {
+		return strings.Count(names[a], ".") < strings.Count(names[b], ".")
+	})
+	for _, name := range names {
+		if strings.Contains(name, ".") {
+			result[name] = copyValues(sections[name])
+		}
+		nest(result, strings.Split(name, "."), sections[name])
 	}
+
 	j, err := json.Marshal(result)
 	if err != nil {
 		return fmt.Errorf("Error trying to parse ini to json: %s", err)
@@ -36,3 +71,129 @@ func (i *Parser) Unmarshal(p []byte, v interface{}) error {
 	}
 	return nil
 }
+
+func (i *Parser) sectionValues(s *ini.Section) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, key := range s.Keys() {
+		shadows := key.ValueWithShadows()
+		if len(shadows) == 1 {
+			values[key.Name()] = i.value(shadows[0])
+			continue
+		}
+
+		var repeated []interface{}
+		for _, shadow := range shadows {
+			repeated = append(repeated, i.value(shadow))
+		}
+		values[key.Name()] = repeated
+	}
+
+	if i.Comments {
+		if comments := sectionComments(s); len(comments) > 0 {
+			values[CommentsKey] = comments
+		}
+	}
+
+	return values
+}
+
+// sectionComments returns the comments of a section and of its keys, without
+// their comment markers
+func sectionComments(s *ini.Section) map[string]interface{} {
+	comments := map[string]interface{}{}
+	if comment := cleanComment(s.Comment); comment != "" {
+		comments["section"] = comment
+	}
+
+	keys := map[string]interface{}{}
+	for _, key := range s.Keys() {
+		if comment := cleanComment(key.Comment); comment != "" {
+			keys[key.Name()] = comment
+		}
+	}
+	if len(keys) > 0 {
+		comments["keys"] = keys
+	}
+
+	return comments
+}
+
+// cleanComment removes the # and ; markers from each line of a comment
+func cleanComment(comment string) string {
+	var lines []string
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#;"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (i *Parser) value(s string) interface{} {
+	if !i.InferTypes {
+		return s
+	}
+
+	switch strings.ToLower(s) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if number.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+
+	return s
+}
+
+// nest adds the values of a section to result at the path given by the
+// segments of its name. Sections that would replace a value are only kept
+// under their full name.
+func nest(result map[string]interface{}, path []string, values map[string]interface{}) {
+	parent := result
+	for _, segment := range path[:len(path)-1] {
+		child, ok := parent[segment]
+		if !ok {
+			child = map[string]interface{}{}
+			parent[segment] = child
+		}
+
+		object, ok := child.(map[string]interface{})
+		if !ok {
+			return
+		}
+		parent = object
+	}
+
+	last := path[len(path)-1]
+	existing, ok := parent[last]
+	if !ok {
+		parent[last] = copyValues(values)
+		return
+	}
+
+	if object, ok := existing.(map[string]interface{}); ok {
+		for key, value := range values {
+			if _, ok := object[key]; !ok {
+				object[key] = value
+			}
+		}
+	}
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{}
+	for key, value := range values {
+		c[key] = value
+	}
+	return c
+}
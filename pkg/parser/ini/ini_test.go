@@ -1,6 +1,7 @@
 package ini
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -33,3 +34,181 @@ func TestIniParser(t *testing.T) {
 		t.Error("There should be at least one item defined in the parsed file, but none found")
 	}
 }
+
+func TestIniParserSections(t *testing.T) {
+	sample := `app_mode = production
+instance_name = grafana
+
+[server]
+http_port = 3000
+enable_gzip = False
+domain = example.com
+enforce_domain = yes
+
+[server.tls]
+min_version = 1.2
+
+[auth.basic]
+enabled = true
+
+[plugins]
+allow = clock
+allow = piechart
+`
+
+	testTable := []struct {
+		name           string
+		parser         *Parser
+		expectedResult interface{}
+	}{
+		{
+			name:   "strings",
+			parser: &Parser{},
+			expectedResult: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{
+					"app_mode":      "production",
+					"instance_name": "grafana",
+				},
+				"server": map[string]interface{}{
+					"http_port":      "3000",
+					"enable_gzip":    "False",
+					"domain":         "example.com",
+					"enforce_domain": "yes",
+					"tls": map[string]interface{}{
+						"min_version": "1.2",
+					},
+				},
+				"server.tls": map[string]interface{}{
+					"min_version": "1.2",
+				},
+				"auth": map[string]interface{}{
+					"basic": map[string]interface{}{
+						"enabled": "true",
+					},
+				},
+				"auth.basic": map[string]interface{}{
+					"enabled": "true",
+				},
+				"plugins": map[string]interface{}{
+					"allow": []interface{}{"clock", "piechart"},
+				},
+			},
+		},
+		{
+			name:   "inferred types",
+			parser: &Parser{InferTypes: true},
+			expectedResult: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{
+					"app_mode":      "production",
+					"instance_name": "grafana",
+				},
+				"server": map[string]interface{}{
+					"http_port":      float64(3000),
+					"enable_gzip":    false,
+					"domain":         "example.com",
+					"enforce_domain": "yes",
+					"tls": map[string]interface{}{
+						"min_version": 1.2,
+					},
+				},
+				"server.tls": map[string]interface{}{
+					"min_version": 1.2,
+				},
+				"auth": map[string]interface{}{
+					"basic": map[string]interface{}{
+						"enabled": true,
+					},
+				},
+				"auth.basic": map[string]interface{}{
+					"enabled": true,
+				},
+				"plugins": map[string]interface{}{
+					"allow": []interface{}{"clock", "piechart"},
+				},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			var input interface{}
+			if err := test.parser.Unmarshal([]byte(sample), &input); err != nil {
+				t.Fatalf("parser should not have thrown an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedResult, input) {
+				t.Errorf("Expected\n%v\nbut got\n%v", test.expectedResult, input)
+			}
+		})
+	}
+}
+
+func TestIniParserComments(t *testing.T) {
+	sample := `# Grafana settings
+app_mode = production
+
+# The HTTP server
+; of every instance
+[server]
+# The port to listen on
+http_port = 3000
+domain = example.com ; the public domain
+enforce_domain = false
+`
+
+	testTable := []struct {
+		name           string
+		parser         *Parser
+		expectedResult interface{}
+	}{
+		{
+			name:   "comments are dropped by default",
+			parser: &Parser{},
+			expectedResult: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{"app_mode": "production"},
+				"server": map[string]interface{}{
+					"http_port":      "3000",
+					"domain":         "example.com",
+					"enforce_domain": "false",
+				},
+			},
+		},
+		{
+			name:   "comments are kept",
+			parser: &Parser{Comments: true},
+			expectedResult: map[string]interface{}{
+				"DEFAULT": map[string]interface{}{
+					"app_mode": "production",
+					CommentsKey: map[string]interface{}{
+						"keys": map[string]interface{}{"app_mode": "Grafana settings"},
+					},
+				},
+				"server": map[string]interface{}{
+					"http_port":      "3000",
+					"domain":         "example.com",
+					"enforce_domain": "false",
+					CommentsKey: map[string]interface{}{
+						"section": "The HTTP server\nof every instance",
+						"keys": map[string]interface{}{
+							"http_port": "The port to listen on",
+							"domain":    "the public domain",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			var input interface{}
+			if err := test.parser.Unmarshal([]byte(sample), &input); err != nil {
+				t.Fatalf("parser should not have thrown an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedResult, input) {
+				t.Errorf("Expected\n%v\nbut got\n%v", test.expectedResult, input)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package jsonnet
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-jsonnet"
+)
+
+// Parser evaluates Jsonnet, so that policies are run against the rendered
+// JSON. Imports are searched for relative to the file and then in JPaths.
+type Parser struct {
+	JPaths  []string
+	ExtVars map[string]string
+}
+
+func (j *Parser) Unmarshal(p []byte, v interface{}) error {
+	return j.UnmarshalFile("", p, v)
+}
+
+// UnmarshalFile evaluates the Jsonnet read from path
+func (j *Parser) UnmarshalFile(path string, p []byte, v interface{}) error {
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{JPaths: j.JPaths})
+	for name, value := range j.ExtVars {
+		vm.ExtVar(name, value)
+	}
+
+	filename := path
+	if filename == "" || filename == "-" {
+		filename = "<stdin>"
+	}
+
+	rendered, err := vm.EvaluateSnippet(filename, string(p))
+	if err != nil {
+		return fmt.Errorf("Unable to evaluate jsonnet: %v", err)
+	}
+
+	err = yaml.Unmarshal([]byte(rendered), v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from jsonnet-json: %s", err)
+	}
+
+	return nil
+}
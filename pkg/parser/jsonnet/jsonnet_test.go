@@ -0,0 +1,64 @@
+package jsonnet_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/parser/jsonnet"
+)
+
+func TestJsonnetParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lib := filepath.Join(dir, "lib")
+	if err := os.MkdirAll(lib, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(lib, "k.libsonnet"):      `{ deployment(name):: { kind: "Deployment", metadata: { name: name } } }`,
+		filepath.Join(dir, "labels.libsonnet"): `{ app: "web" }`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sample := `local k = import "k.libsonnet";
+local labels = import "labels.libsonnet";
+k.deployment(std.extVar("name")) + { metadata+: { labels: labels }, replicas: 1 + 2 }
+`
+
+	parser := &jsonnet.Parser{
+		JPaths:  []string{lib},
+		ExtVars: map[string]string{"name": "web"},
+	}
+
+	var input interface{}
+	if err := parser.UnmarshalFile(filepath.Join(dir, "deployment.jsonnet"), []byte(sample), &input); err != nil {
+		t.Fatalf("parser should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"kind":     "Deployment",
+		"replicas": float64(3),
+		"metadata": map[string]interface{}{
+			"name":   "web",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+	}
+	if !reflect.DeepEqual(expected, input) {
+		t.Errorf("Expected\n%v\nbut got\n%v", expected, input)
+	}
+
+	if err := new(jsonnet.Parser).Unmarshal([]byte(`{ name: std.extVar("missing") }`), &input); err == nil {
+		t.Error("we expected an error for an undefined external variable")
+	}
+}
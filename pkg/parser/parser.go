@@ -5,12 +5,21 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"os"
+	"strings"
 
+	"github.com/instrumenta/conftest/pkg/parser/cloudformation"
+	"github.com/instrumenta/conftest/pkg/parser/compose"
 	"github.com/instrumenta/conftest/pkg/parser/cue"
 	"github.com/instrumenta/conftest/pkg/parser/docker"
+	"github.com/instrumenta/conftest/pkg/parser/dotenv"
+	"github.com/instrumenta/conftest/pkg/parser/hocon"
 	"github.com/instrumenta/conftest/pkg/parser/ini"
+	"github.com/instrumenta/conftest/pkg/parser/jsonnet"
+	"github.com/instrumenta/conftest/pkg/parser/properties"
 	"github.com/instrumenta/conftest/pkg/parser/terraform"
 	"github.com/instrumenta/conftest/pkg/parser/toml"
+	"github.com/instrumenta/conftest/pkg/parser/xml"
 	"github.com/instrumenta/conftest/pkg/parser/yaml"
 )
 
@@ -20,7 +29,14 @@ func ValidInputs() []string {
 		"toml",
 		"tf|hcl",
 		"cue",
+		"hocon",
+		"jsonnet",
 		"ini",
+		"properties",
+		"env|dotenv",
+		"xml",
+		"compose",
+		"cloudformation|cfn",
 		"yaml",
 		"json",
 	}
@@ -32,6 +48,20 @@ type Parser interface {
 	Unmarshal(p []byte, v interface{}) error
 }
 
+// FileParser is implemented by parsers that need the path of the file they
+// parse, for example to resolve imports relative to it. The path is "-" when
+// reading from stdin.
+type FileParser interface {
+	UnmarshalFile(path string, p []byte, v interface{}) error
+}
+
+// InstanceParser is implemented by parsers that evaluate several files as one
+// instance, such as the files of a CUE package. Files of the same instance are
+// only unmarshalled once, under the name of the instance.
+type InstanceParser interface {
+	Instance(path string, p []byte) string
+}
+
 // ConfigDoc stores file contents and it's original filename
 type ConfigDoc struct {
 	ReadCloser io.ReadCloser
@@ -60,12 +90,25 @@ func (s *ConfigManager) BulkUnmarshal(configList []ConfigDoc) (map[string]interf
 	}
 	var allContents = make(map[string]interface{})
 	for filepath, config := range s.configContents {
+		key := filepath
+		if instanceParser, ok := s.parser.(InstanceParser); ok {
+			key = instanceParser.Instance(filepath, config)
+			if _, ok := allContents[key]; ok {
+				continue
+			}
+		}
+
 		var singleContent interface{}
-		err := s.parser.Unmarshal(config, &singleContent)
+		var err error
+		if fileParser, ok := s.parser.(FileParser); ok {
+			err = fileParser.UnmarshalFile(filepath, config, &singleContent)
+		} else {
+			err = s.parser.Unmarshal(config, &singleContent)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("Should not have any errors on unmarshalling: %v", err)
 		}
-		allContents[filepath] = singleContent
+		allContents[key] = singleContent
 	}
 	return allContents, nil
 }
@@ -86,9 +129,46 @@ func (s *ConfigManager) setConfigs(configList []ConfigDoc) error {
 	return nil
 }
 
+// Options configures the parsers returned by GetParserWithOptions. The zero
+// value gives the default behaviour of each parser.
+type Options struct {
+	// DockerfileStages groups the instructions of Dockerfiles into build stages
+	DockerfileStages bool
+
+	// NestProperties nests the dotted keys of .properties files into objects
+	NestProperties bool
+
+	// INIInferTypes converts booleans and numbers in INI files from strings
+	INIInferTypes bool
+
+	// INIComments keeps the comments of INI sections and keys
+	INIComments bool
+
+	// JsonnetJPaths are the library directories searched for Jsonnet imports
+	JsonnetJPaths []string
+
+	// JsonnetExtVars are Jsonnet external variables given as name=value, or
+	// as name to read the value from the environment
+	JsonnetExtVars []string
+
+	// ComposeOverrides are the files merged into Docker Compose files
+	ComposeOverrides []string
+
+	// ComposeEnv are the variables used to interpolate Docker Compose files,
+	// given as name=value. Later variables take precedence over earlier ones
+	// and over the .env file. The environment is not read unless given here.
+	ComposeEnv []string
+}
+
 // NewConfigManager is the instatiation function for ConfigManager
 func NewConfigManager(fileType string) ReadUnmarshaller {
-	parser, err := GetParser(fileType)
+	return NewConfigManagerWithOptions(fileType, Options{})
+}
+
+// NewConfigManagerWithOptions creates a ConfigManager whose parser is
+// configured with the given options
+func NewConfigManagerWithOptions(fileType string, options Options) ReadUnmarshaller {
+	parser, err := GetParserWithOptions(fileType, options)
 	if err != nil {
 		log.Fatalf("we failed to create the parser: %v", err)
 	}
@@ -100,6 +180,12 @@ func NewConfigManager(fileType string) ReadUnmarshaller {
 
 // GetParser gets a parser that works on a given fileType
 func GetParser(fileType string) (Parser, error) {
+	return GetParserWithOptions(fileType, Options{})
+}
+
+// GetParserWithOptions gets a parser that works on a given fileType,
+// configured with the given options
+func GetParserWithOptions(fileType string, options Options) (Parser, error) {
 	switch fileType {
 	case "toml":
 		return &toml.Parser{}, nil
@@ -107,13 +193,78 @@ func GetParser(fileType string) (Parser, error) {
 		return &terraform.Parser{}, nil
 	case "cue":
 		return &cue.Parser{}, nil
+	case "hocon":
+		return &hocon.Parser{}, nil
+	case "jsonnet", "libsonnet":
+		return newJsonnetParser(options)
 	case "ini":
-		return &ini.Parser{}, nil
+		return &ini.Parser{InferTypes: options.INIInferTypes, Comments: options.INIComments}, nil
+	case "properties":
+		return &properties.Parser{Nest: options.NestProperties}, nil
+	case "env", "dotenv":
+		return &dotenv.Parser{}, nil
+	case "xml", "pom", "csproj", "vbproj", "fsproj", "props", "targets", "nuspec":
+		return &xml.Parser{}, nil
+	case "compose":
+		return newComposeParser(options)
+	case "cloudformation", "cfn":
+		return &cloudformation.Parser{}, nil
 	case "Dockerfile":
-		return &docker.Parser{}, nil
+		return &docker.Parser{Stages: options.DockerfileStages}, nil
 	case "yml", "yaml", "json":
 		return &yaml.Parser{}, nil
 	default:
 		return nil, fmt.Errorf("unknown filetype given: %v", fileType)
 	}
 }
+
+// newJsonnetParser creates a Jsonnet parser with the library paths and
+// external variables given in the options. External variables are given as
+// name=value, or as name to read the value from the environment.
+func newJsonnetParser(options Options) (Parser, error) {
+	parser := &jsonnet.Parser{}
+	if len(options.JsonnetJPaths) > 0 {
+		parser.JPaths = options.JsonnetJPaths
+	}
+
+	for _, extVar := range options.JsonnetExtVars {
+		if parser.ExtVars == nil {
+			parser.ExtVars = map[string]string{}
+		}
+
+		parts := strings.SplitN(extVar, "=", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid jsonnet external variable %q, expected name=value", extVar)
+		}
+		if len(parts) == 1 {
+			parser.ExtVars[parts[0]] = os.Getenv(parts[0])
+			continue
+		}
+		parser.ExtVars[parts[0]] = parts[1]
+	}
+
+	return parser, nil
+}
+
+// newComposeParser creates a Docker Compose parser with the override files and
+// variables given in the options. Variables are given as name=value.
+func newComposeParser(options Options) (Parser, error) {
+	parser := &compose.Parser{}
+	if len(options.ComposeOverrides) > 0 {
+		parser.Overrides = options.ComposeOverrides
+	}
+
+	for _, variable := range options.ComposeEnv {
+		if parser.Env == nil {
+			parser.Env = map[string]string{}
+		}
+
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected name=value", variable)
+		}
+		parser.Env[parts[0]] = parts[1]
+	}
+
+	return parser, nil
+}
@@ -1,16 +1,26 @@
 package parser_test
 
 import (
+	"bytes"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/instrumenta/conftest/pkg/parser"
+	"github.com/instrumenta/conftest/pkg/parser/cloudformation"
+	"github.com/instrumenta/conftest/pkg/parser/compose"
 	"github.com/instrumenta/conftest/pkg/parser/cue"
+	"github.com/instrumenta/conftest/pkg/parser/docker"
+	"github.com/instrumenta/conftest/pkg/parser/dotenv"
+	"github.com/instrumenta/conftest/pkg/parser/hocon"
 	"github.com/instrumenta/conftest/pkg/parser/ini"
+	"github.com/instrumenta/conftest/pkg/parser/jsonnet"
+	"github.com/instrumenta/conftest/pkg/parser/properties"
 	"github.com/instrumenta/conftest/pkg/parser/terraform"
 	"github.com/instrumenta/conftest/pkg/parser/toml"
+	"github.com/instrumenta/conftest/pkg/parser/xml"
 	"github.com/instrumenta/conftest/pkg/parser/yaml"
 )
 
@@ -178,6 +188,38 @@ nice: true`)),
 	})
 }
 
+func TestUnmarshallerInstances(t *testing.T) {
+	var configList []parser.ConfigDoc
+	for _, name := range []string{"deployment.cue", "templates.cue"} {
+		path := filepath.Join("..", "..", "examples", "cue", name)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configList = append(configList, parser.ConfigDoc{
+			ReadCloser: ioutil.NopCloser(bytes.NewReader(contents)),
+			Filepath:   path,
+		})
+	}
+
+	configurations, err := parser.NewConfigManager("cue").BulkUnmarshal(configList)
+	if err != nil {
+		t.Fatalf("we should not have any errors on unmarshalling: %v", err)
+	}
+
+	if len(configurations) != 1 {
+		t.Fatalf("Expected the files of a package to be unmarshalled once, got %d configurations", len(configurations))
+	}
+	for name, configuration := range configurations {
+		if name != filepath.Join("..", "..", "examples", "cue")+":kubernetes" {
+			t.Errorf("Expected the configuration to be named after the package, got %s", name)
+		}
+		if _, ok := configuration.(map[string]interface{})["deployment"]; !ok {
+			t.Errorf("Expected the package to be evaluated, got %v", configuration)
+		}
+	}
+}
+
 func TestGetParser(t *testing.T) {
 	testTable := []struct {
 		name        string
@@ -215,6 +257,60 @@ func TestGetParser(t *testing.T) {
 			expected:    new(ini.Parser),
 			expectError: false,
 		},
+		{
+			name:        "Test getting HOCON parser",
+			fileType:    "hocon",
+			expected:    new(hocon.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test conf files are not assumed to be HOCON",
+			fileType:    "conf",
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name:        "Test getting Jsonnet parser",
+			fileType:    "jsonnet",
+			expected:    new(jsonnet.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting properties parser",
+			fileType:    "properties",
+			expected:    new(properties.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting dotenv parser",
+			fileType:    "env",
+			expected:    new(dotenv.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting XML parser",
+			fileType:    "xml",
+			expected:    new(xml.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting XML parser from .csproj input",
+			fileType:    "csproj",
+			expected:    new(xml.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting Docker Compose parser",
+			fileType:    "compose",
+			expected:    new(compose.Parser),
+			expectError: false,
+		},
+		{
+			name:        "Test getting CloudFormation parser",
+			fileType:    "cloudformation",
+			expected:    new(cloudformation.Parser),
+			expectError: false,
+		},
 		{
 			name:        "Test getting YAML parser from JSON input",
 			fileType:    "json",
@@ -251,3 +347,42 @@ func TestGetParser(t *testing.T) {
 		})
 	}
 }
+
+func TestGetParserWithOptions(t *testing.T) {
+	options := parser.Options{
+		DockerfileStages: true,
+		NestProperties:   true,
+		INIInferTypes:    true,
+		JsonnetJPaths:    []string{"lib"},
+		JsonnetExtVars:   []string{"env=prod"},
+		ComposeOverrides: []string{"docker-compose.ci.yml"},
+		ComposeEnv:       []string{"TAG=1.0"},
+	}
+
+	testTable := []struct {
+		fileType string
+		expected parser.Parser
+	}{
+		{"Dockerfile", &docker.Parser{Stages: true}},
+		{"properties", &properties.Parser{Nest: true}},
+		{"ini", &ini.Parser{InferTypes: true}},
+		{"jsonnet", &jsonnet.Parser{JPaths: []string{"lib"}, ExtVars: map[string]string{"env": "prod"}}},
+		{"compose", &compose.Parser{Overrides: []string{"docker-compose.ci.yml"}, Env: map[string]string{"TAG": "1.0"}}},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.fileType, func(t *testing.T) {
+			received, err := parser.GetParserWithOptions(test.fileType, options)
+			if err != nil {
+				t.Fatalf("we did not expect to see an error here: %v", err)
+			}
+			if !reflect.DeepEqual(received, test.expected) {
+				t.Errorf("expected: %+v \n got this: %+v", test.expected, received)
+			}
+		})
+	}
+
+	if _, err := parser.GetParserWithOptions("compose", parser.Options{ComposeEnv: []string{"TAG"}}); err == nil {
+		t.Error("we did not see an error for a variable without a value")
+	}
+}
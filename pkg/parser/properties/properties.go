@@ -0,0 +1,190 @@
+package properties
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Parser parses Java .properties files into a map of keys to string values.
+// When Nest is set, dotted keys such as server.port are nested into objects.
+type Parser struct {
+	Nest bool
+}
+
+func (pp *Parser) Unmarshal(p []byte, v interface{}) error {
+	properties, err := parse(p)
+	if err != nil {
+		return fmt.Errorf("Unable to parse properties: %v", err)
+	}
+
+	var result interface{} = properties
+	if pp.Nest {
+		result, err = nest(properties)
+		if err != nil {
+			return fmt.Errorf("Unable to parse properties: %v", err)
+		}
+	}
+
+	j, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse properties to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from properties-json: %s", err)
+	}
+
+	return nil
+}
+
+// parse reads the logical lines of a properties file, following the format
+// described by java.util.Properties
+func parse(p []byte) (map[string]string, error) {
+	properties := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		start := number
+		for continues(line) && scanner.Scan() {
+			number++
+			line = line[:len(line)-1] + strings.TrimLeft(scanner.Text(), " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+
+		key, value := split(line)
+		unescapedKey, err := unescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		unescapedValue, err := unescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		properties[unescapedKey] = unescapedValue
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
+
+// continues reports whether a line ends with an odd number of backslashes,
+// meaning it continues on the next line
+func continues(line string) bool {
+	backslashes := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		backslashes++
+	}
+
+	return backslashes%2 == 1
+}
+
+// split separates a line into its key and value. The key ends at the first
+// unescaped '=', ':' or whitespace, which may be surrounded by whitespace.
+func split(line string) (string, string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.ContainsRune("=: \t\f", rune(line[i])) {
+			end = i
+			break
+		}
+	}
+
+	key := line[:end]
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	return key, rest
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid unicode escape %q", s[i-1:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape %q", s[i-1:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// nest turns dotted keys into nested objects, so server.port=8080 becomes
+// {"server": {"port": "8080"}}. A key can't hold both a value and nested keys.
+func nest(properties map[string]string) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, value := range properties {
+		segments := strings.Split(key, ".")
+		current := result
+		for i, segment := range segments[:len(segments)-1] {
+			switch child := current[segment].(type) {
+			case nil:
+				next := map[string]interface{}{}
+				current[segment] = next
+				current = next
+			case map[string]interface{}:
+				current = child
+			default:
+				return nil, fmt.Errorf("key %s conflicts with key %s", key, strings.Join(segments[:i+1], "."))
+			}
+		}
+
+		last := segments[len(segments)-1]
+		if _, ok := current[last].(map[string]interface{}); ok {
+			return nil, fmt.Errorf("key %s conflicts with keys nested below it", key)
+		}
+		current[last] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package properties_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/parser/properties"
+)
+
+func TestPropertiesParser(t *testing.T) {
+	sample := `# Spring Boot settings
+! also a comment
+server.port=8080
+server.address : 127.0.0.1
+spring.datasource.url jdbc:postgresql://localhost/app
+spring.datasource.password=
+management.endpoints.web.exposure.include=health,\
+    info,\
+    metrics
+app.greeting=Hello\tWorld é
+app.key\=with\:separators=value
+app.title=Caf\u00e9 \u2603
+`
+
+	testTable := []struct {
+		name           string
+		parser         *properties.Parser
+		expectedResult interface{}
+	}{
+		{
+			name:   "flat keys",
+			parser: &properties.Parser{},
+			expectedResult: map[string]interface{}{
+				"server.port":                               "8080",
+				"server.address":                            "127.0.0.1",
+				"spring.datasource.url":                     "jdbc:postgresql://localhost/app",
+				"spring.datasource.password":                "",
+				"management.endpoints.web.exposure.include": "health,info,metrics",
+				"app.greeting":                              "Hello\tWorld é",
+				"app.key=with:separators":                   "value",
+				"app.title":                                 "Café ☃",
+			},
+		},
+		{
+			name:   "nested keys",
+			parser: &properties.Parser{Nest: true},
+			expectedResult: map[string]interface{}{
+				"server": map[string]interface{}{
+					"port":    "8080",
+					"address": "127.0.0.1",
+				},
+				"spring": map[string]interface{}{
+					"datasource": map[string]interface{}{
+						"url":      "jdbc:postgresql://localhost/app",
+						"password": "",
+					},
+				},
+				"management": map[string]interface{}{
+					"endpoints": map[string]interface{}{
+						"web": map[string]interface{}{
+							"exposure": map[string]interface{}{
+								"include": "health,info,metrics",
+							},
+						},
+					},
+				},
+				"app": map[string]interface{}{
+					"greeting":            "Hello\tWorld é",
+					"key=with:separators": "value",
+					"title":               "Café ☃",
+				},
+			},
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			var input interface{}
+			if err := test.parser.Unmarshal([]byte(sample), &input); err != nil {
+				t.Fatalf("parser should not have thrown an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedResult, input) {
+				t.Errorf("Expected\n%v\nbut got\n%v", test.expectedResult, input)
+			}
+		})
+	}
+}
+
+func TestPropertiesParserNestingConflict(t *testing.T) {
+	parser := &properties.Parser{Nest: true}
+
+	var input interface{}
+	if err := parser.Unmarshal([]byte("logging.level=INFO\nlogging.level.root=WARN\n"), &input); err == nil {
+		t.Error("we expected an error for a key that is both a value and an object")
+	}
+}
+
+func TestPropertiesParserInvalidUnicodeEscape(t *testing.T) {
+	parser := &properties.Parser{}
+
+	for _, sample := range []string{"app.title=Caf\\u00", "app.title=Caf\\u00zz"} {
+		var input interface{}
+		if err := parser.Unmarshal([]byte(sample), &input); err == nil {
+			t.Errorf("we expected an error for the invalid escape in %q", sample)
+		}
+	}
+}
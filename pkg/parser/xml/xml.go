@@ -0,0 +1,159 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// AttributePrefix is prepended to the names of attributes, so they can't
+// clash with child elements of the same name
+const AttributePrefix = "@"
+
+// TextKey holds the text of elements that also have attributes or children
+const TextKey = "#text"
+
+// Parser parses XML documents. Each element becomes an object of its
+// attributes and child elements, repeated child elements become arrays, and
+// elements with only text become strings.
+type Parser struct{}
+
+type element struct {
+	name       string
+	children   map[string]interface{}
+	text       strings.Builder
+	namespaces map[string]string
+}
+
+// xmlNamespace is the namespace the xml prefix is bound to
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// newElement creates the element for a start tag. The decoder replaces the
+// prefix of an attribute with the namespace it is bound to, so the prefixes
+// declared on the element and its parents are tracked to key attributes by
+// their prefixed name, such as @xsi:schemaLocation.
+func newElement(start xml.StartElement, parent *element) *element {
+	e := &element{
+		name:       start.Name.Local,
+		children:   map[string]interface{}{},
+		namespaces: map[string]string{xmlNamespace: "xml"},
+	}
+	if parent != nil {
+		for namespace, prefix := range parent.namespaces {
+			e.namespaces[namespace] = prefix
+		}
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" {
+			e.namespaces[attr.Value] = attr.Name.Local
+		}
+	}
+
+	for _, attr := range start.Attr {
+		e.children[AttributePrefix+e.attributeName(attr.Name)] = attr.Value
+	}
+
+	return e
+}
+
+func (e *element) attributeName(name xml.Name) string {
+	switch {
+	case name.Space == "":
+		return name.Local
+	case name.Space == "xmlns":
+		return "xmlns:" + name.Local
+	case e.namespaces[name.Space] != "":
+		return e.namespaces[name.Space] + ":" + name.Local
+	default:
+		// prefixes that were never declared are left as they are
+		return name.Space + ":" + name.Local
+	}
+}
+
+func (e *element) add(name string, value interface{}) {
+	existing, ok := e.children[name]
+	if !ok {
+		e.children[name] = value
+		return
+	}
+
+	if values, ok := existing.([]interface{}); ok {
+		e.children[name] = append(values, value)
+		return
+	}
+	e.children[name] = []interface{}{existing, value}
+}
+
+func (e *element) value() interface{} {
+	text := strings.TrimSpace(e.text.String())
+	if len(e.children) == 0 {
+		return text
+	}
+	if text != "" {
+		e.children[TextKey] = text
+	}
+
+	return e.children
+}
+
+// Unmarshal parses the XML document in p into v, keyed by the name of its
+// root element
+func (x *Parser) Unmarshal(p []byte, v interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(p))
+
+	var root map[string]interface{}
+	var stack []*element
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to parse XML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && root != nil {
+				return fmt.Errorf("Unable to parse XML: document has more than one root element")
+			}
+			var parent *element
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, newElement(t, parent))
+		case xml.EndElement:
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				root = map[string]interface{}{current.name: current.value()}
+			} else {
+				stack[len(stack)-1].add(current.name, current.value())
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return fmt.Errorf("Unable to parse XML: document has no root element")
+	}
+
+	j, err := json.Marshal(root)
+	if err != nil {
+		return fmt.Errorf("Error trying to parse xml to json: %s", err)
+	}
+	err = yaml.Unmarshal(j, v)
+	if err != nil {
+		return fmt.Errorf("Unable to parse YAML from xml-json: %s", err)
+	}
+
+	return nil
+}
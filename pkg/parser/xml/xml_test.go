@@ -0,0 +1,127 @@
+package xml_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/parser/xml"
+)
+
+func TestXMLParser(t *testing.T) {
+	testTable := []struct {
+		name           string
+		controlConfigs []byte
+		expectedResult interface{}
+		shouldError    bool
+	}{
+		{
+			name: "a maven pom",
+			controlConfigs: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <!-- comments are ignored -->
+  <modelVersion>4.0.0</modelVersion>
+  <dependencies>
+    <dependency>
+      <groupId>junit</groupId>
+      <version>4.12</version>
+    </dependency>
+    <dependency>
+      <groupId>log4j</groupId>
+      <version>1.2.17</version>
+    </dependency>
+  </dependencies>
+</project>`),
+			expectedResult: map[string]interface{}{
+				"project": map[string]interface{}{
+					"@xmlns":       "http://maven.apache.org/POM/4.0.0",
+					"modelVersion": "4.0.0",
+					"dependencies": map[string]interface{}{
+						"dependency": []interface{}{
+							map[string]interface{}{"groupId": "junit", "version": "4.12"},
+							map[string]interface{}{"groupId": "log4j", "version": "1.2.17"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "attributes, text and empty elements",
+			controlConfigs: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <PackageReference Include="Newtonsoft.Json" Version="12.0.2" />
+  <Description lang="en">A <![CDATA[sample]]> project</Description>
+  <Empty/>
+</Project>`),
+			expectedResult: map[string]interface{}{
+				"Project": map[string]interface{}{
+					"@Sdk": "Microsoft.NET.Sdk",
+					"PackageReference": map[string]interface{}{
+						"@Include": "Newtonsoft.Json",
+						"@Version": "12.0.2",
+					},
+					"Description": map[string]interface{}{
+						"@lang": "en",
+						"#text": "A sample project",
+					},
+					"Empty": "",
+				},
+			},
+		},
+		{
+			name: "namespaced attributes keep their prefix",
+			controlConfigs: []byte(`<beans xmlns="http://www.springframework.org/schema/beans" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.springframework.org/schema/beans">
+  <bean id="a" xml:lang="en" xmlns:p="http://www.springframework.org/schema/p" p:id="b"/>
+  <bean id="c" xsi:type="d"/>
+</beans>`),
+			expectedResult: map[string]interface{}{
+				"beans": map[string]interface{}{
+					"@xmlns":              "http://www.springframework.org/schema/beans",
+					"@xmlns:xsi":          "http://www.w3.org/2001/XMLSchema-instance",
+					"@xsi:schemaLocation": "http://www.springframework.org/schema/beans",
+					"bean": []interface{}{
+						map[string]interface{}{
+							"@id":       "a",
+							"@xml:lang": "en",
+							"@xmlns:p":  "http://www.springframework.org/schema/p",
+							"@p:id":     "b",
+						},
+						map[string]interface{}{
+							"@id":       "c",
+							"@xsi:type": "d",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:           "unclosed elements",
+			controlConfigs: []byte(`<project><name>conftest</project>`),
+			shouldError:    true,
+		},
+		{
+			name:           "multiple root elements",
+			controlConfigs: []byte(`<project/><project/>`),
+			shouldError:    true,
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			var unmarshalledConfigs interface{}
+			parser := new(xml.Parser)
+			err := parser.Unmarshal(test.controlConfigs, &unmarshalledConfigs)
+			if test.shouldError {
+				if err == nil {
+					t.Error("we expected an error but did not see one")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("we should not have any errors on unmarshalling: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.expectedResult, unmarshalledConfigs) {
+				t.Errorf("Expected\n%v\nbut got\n%v", test.expectedResult, unmarshalledConfigs)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	"github.com/containerd/containerd/log"
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/open-policy-agent/opa/ast"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// ManifestFileName is the name of the Open Policy Agent bundle manifest
+	ManifestFileName = ".manifest"
+
+	// PoliciesDirectory is the subdirectory pulled policies are written to
+	PoliciesDirectory = "policies"
+
+	// DataDirectory is the subdirectory pulled data files are written to
+	DataDirectory = "data"
+)
+
+// Manifest describes a policy bundle, following the Open Policy Agent bundle
+// manifest format
+type Manifest struct {
+	Revision string   `json:"revision,omitempty"`
+	Roots    []string `json:"roots"`
+}
+
+// Config describes the contents of a bundle, it is pushed as the config blob
+// of the bundle manifest
+type Config struct {
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// Namespace lists the rules a bundle defines in a package
+type Namespace struct {
+	Name  string   `json:"name"`
+	Rules []string `json:"rules"`
+}
+
+// bundleMediaTypes are the layer media types pulled from a bundle
+var bundleMediaTypes = []string{
+	constants.OpenPolicyAgentPolicyLayerMediaType,
+	constants.OpenPolicyAgentDataLayerMediaType,
+	constants.OpenPolicyAgentYAMLDataLayerMediaType,
+	constants.OpenPolicyAgentManifestLayerMediaType,
+}
+
+// PackageRoot returns the package of a module as a slash separated path, the
+// form used for bundle roots
+func PackageRoot(module *ast.Module) string {
+	var segments []string
+	for _, term := range module.Package.Path[1:] {
+		if segment, ok := term.Value.(ast.String); ok {
+			segments = append(segments, string(segment))
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// DirectoryLayers reads the policies and data files below root into a memory
+// store, as layers named after their path relative to root. When skipHidden
+// is set, hidden directories such as .git are skipped, which is wanted for
+// fetched sources but not for the bundles being pushed.
+func DirectoryLayers(root string, skipHidden bool) (*content.Memorystore, []ocispec.Descriptor, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// filepath.Walk does not descend into a root that is a symbolic link
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s isn't a directory", root)
+	}
+
+	var data []string
+	var yamlData []string
+	var policies []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if skipHidden && path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".rego":
+			policies = append(policies, path)
+		case ".json":
+			data = append(data, path)
+		case ".yaml", ".yml":
+			yamlData = append(yamlData, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	memoryStore := content.NewMemoryStore()
+	var layers []ocispec.Descriptor
+	for _, files := range []struct {
+		paths     []string
+		mediaType string
+	}{
+		{policies, constants.OpenPolicyAgentPolicyLayerMediaType},
+		{data, constants.OpenPolicyAgentDataLayerMediaType},
+		{yamlData, constants.OpenPolicyAgentYAMLDataLayerMediaType},
+	} {
+		for _, file := range files.paths {
+			contents, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			relative, err := filepath.Rel(root, file)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			layers = append(layers, memoryStore.Add(filepath.ToSlash(relative), files.mediaType, contents))
+		}
+	}
+
+	return memoryStore, layers, nil
+}
+
+// layerPath returns where a pulled layer is written to, relative to the policy
+// directory. Policies and data are kept apart, and the layout they were pushed
+// with is preserved below that.
+func layerPath(layer ocispec.Descriptor) (string, error) {
+	name := layer.Annotations[ocispec.AnnotationTitle]
+	if name == "" {
+		return "", fmt.Errorf("layer %s has no file name", layer.Digest)
+	}
+
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("layer %s has an invalid file name %q", layer.Digest, name)
+	}
+
+	switch layer.MediaType {
+	case constants.OpenPolicyAgentManifestLayerMediaType:
+		return ManifestFileName, nil
+	case constants.OpenPolicyAgentDataLayerMediaType, constants.OpenPolicyAgentYAMLDataLayerMediaType:
+		return filepath.Join(DataDirectory, filepath.FromSlash(clean)), nil
+	default:
+		return filepath.Join(PoliciesDirectory, filepath.FromSlash(clean)), nil
+	}
+}
+
+// writeLayers writes the pulled layers from the memory store into dir.
+func writeLayers(ctx context.Context, dir string, memoryStore *content.Memorystore, layers []ocispec.Descriptor) error {
+	for _, layer := range layers {
+		_, contents, ok := memoryStore.Get(layer)
+		if !ok {
+			return fmt.Errorf("layer %s was not downloaded", layer.Digest)
+		}
+
+		relative, err := layerPath(layer)
+		if err != nil {
+			return err
+		}
+
+		file := filepath.Join(dir, relative)
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			return err
+		}
+
+		log.G(ctx).Debugf("Writing %s\n", file)
+		if err := ioutil.WriteFile(file, contents, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
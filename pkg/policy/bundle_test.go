@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestLayerPath(t *testing.T) {
+	layer := func(name string, mediaType string) ocispec.Descriptor {
+		return ocispec.Descriptor{
+			MediaType:   mediaType,
+			Annotations: map[string]string{ocispec.AnnotationTitle: name},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		layer       ocispec.Descriptor
+		expected    string
+		shouldError bool
+	}{
+		{
+			name:     "policies keep their layout under the policies directory",
+			layer:    layer("lib/kubernetes.rego", constants.OpenPolicyAgentPolicyLayerMediaType),
+			expected: filepath.Join("policies", "lib", "kubernetes.rego"),
+		},
+		{
+			name:     "JSON data is written to the data directory",
+			layer:    layer("config/data.json", constants.OpenPolicyAgentDataLayerMediaType),
+			expected: filepath.Join("data", "config", "data.json"),
+		},
+		{
+			name:     "YAML data is written to the data directory",
+			layer:    layer("data.yaml", constants.OpenPolicyAgentYAMLDataLayerMediaType),
+			expected: filepath.Join("data", "data.yaml"),
+		},
+		{
+			name:     "the manifest is written to the root",
+			layer:    layer(".manifest", constants.OpenPolicyAgentManifestLayerMediaType),
+			expected: ".manifest",
+		},
+		{
+			name:        "paths outside the policy directory are rejected",
+			layer:       layer("../../etc/passwd", constants.OpenPolicyAgentPolicyLayerMediaType),
+			shouldError: true,
+		},
+		{
+			name:        "absolute paths are rejected",
+			layer:       layer("/etc/passwd", constants.OpenPolicyAgentDataLayerMediaType),
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := layerPath(test.layer)
+			if test.shouldError && err == nil {
+				t.Fatal("we expected an error but did not get one")
+			}
+			if !test.shouldError && err != nil {
+				t.Fatalf("we did not expect an error: %v", err)
+			}
+
+			if actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
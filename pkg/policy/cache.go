@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/deislabs/oras/pkg/content"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Cache stores downloaded policies on disk so that they can be reused
+// without contacting their source. Layers are stored by digest, and each
+// reference records the layers it last resolved to.
+type Cache struct {
+	Root string
+}
+
+type cacheEntry struct {
+	Reference string               `json:"reference"`
+	Digest    digest.Digest        `json:"digest"`
+	Signature []byte               `json:"signature,omitempty"`
+	Fetched   time.Time            `json:"fetched"`
+	Layers    []ocispec.Descriptor `json:"layers"`
+}
+
+// DefaultCacheDirectory returns the directory policies are cached in, which
+// is conftest inside the user cache directory ($XDG_CACHE_HOME on Linux)
+func DefaultCacheDirectory() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "conftest"), nil
+}
+
+// NewCache creates a cache rooted at the given directory
+func NewCache(root string) *Cache {
+	return &Cache{Root: root}
+}
+
+func (c *Cache) entryPath(reference string) string {
+	sum := sha256.Sum256([]byte(reference))
+	return filepath.Join(c.Root, "refs", hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) blobPath(d digest.Digest) string {
+	return filepath.Join(c.Root, "blobs", d.Algorithm().String(), d.Hex())
+}
+
+// load reads the cached layers of a reference into a memory store. A missing
+// entry is reported with an error satisfying os.IsNotExist.
+func (c *Cache) load(reference string) (cacheEntry, *content.Memorystore, error) {
+	var entry cacheEntry
+	contents, err := ioutil.ReadFile(c.entryPath(reference))
+	if err != nil {
+		return entry, nil, err
+	}
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return entry, nil, fmt.Errorf("Unable to read cache entry for %s: %v", reference, err)
+	}
+
+	memoryStore := content.NewMemoryStore()
+	for _, layer := range entry.Layers {
+		if err := layer.Digest.Validate(); err != nil {
+			return entry, nil, fmt.Errorf("Unable to read cache entry for %s: %v", reference, err)
+		}
+
+		blob, err := ioutil.ReadFile(c.blobPath(layer.Digest))
+		if err != nil {
+			return entry, nil, err
+		}
+		if layer.Digest.Algorithm().FromBytes(blob) != layer.Digest {
+			return entry, nil, fmt.Errorf("cached layer %s of %s is corrupt", layer.Digest, reference)
+		}
+
+		memoryStore.Set(layer, blob)
+	}
+
+	return entry, memoryStore, nil
+}
+
+// store writes the layers of a reference to the cache, replacing any entry
+// previously recorded for it
+func (c *Cache) store(entry cacheEntry, memoryStore *content.Memorystore) error {
+	for _, layer := range entry.Layers {
+		_, blob, ok := memoryStore.Get(layer)
+		if !ok {
+			return fmt.Errorf("layer %s was not downloaded", layer.Digest)
+		}
+
+		if err := writeFileAtomic(c.blobPath(layer.Digest), blob); err != nil {
+			return err
+		}
+	}
+
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.entryPath(entry.Reference), contents)
+}
+
+// writeFileAtomic writes the file through a temporary file in the same
+// directory, so that concurrent runs never see a partially written file
+func writeFileAtomic(path string, contents []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheReference returns the key a policy is cached under
+func cacheReference(policy Policy) string {
+	if IsBundleURL(policy.Repository) || isSourcePath(policy.Repository) {
+		return policy.Repository
+	}
+
+	return getRepositoryFromPolicy(policy)
+}
+
+// usable reports whether a cached entry can be used in place of downloading
+// the policy. Offline every matching entry is used, otherwise only entries
+// younger than the TTL or pinned to a digest. When keys are given, the cached
+// signature must verify against them, so an entry verified under other keys
+// is never trusted.
+func (e cacheEntry) usable(policy Policy, keys []crypto.PublicKey, ttl time.Duration, offline bool) bool {
+	if policy.Digest != "" && policy.Digest != e.Digest.String() {
+		return false
+	}
+	if len(keys) > 0 && Verify(keys, e.Digest, e.Signature) != nil {
+		return false
+	}
+	if offline || policy.Digest != "" {
+		return true
+	}
+
+	return ttl > 0 && time.Since(e.Fetched) < ttl
+}
@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/instrumenta/conftest/pkg/constants"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir)
+	reference := "localhost:5000/policies:latest"
+	if _, _, err := cache.load(reference); !os.IsNotExist(err) {
+		t.Fatalf("Expected a missing entry, got %v", err)
+	}
+
+	memoryStore := content.NewMemoryStore()
+	layer := memoryStore.Add("main.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte("package main"))
+	entry := cacheEntry{
+		Reference: reference,
+		Digest:    digest.FromString("manifest"),
+		Fetched:   time.Now(),
+		Layers:    []ocispec.Descriptor{layer},
+	}
+	if err := cache.store(entry, memoryStore); err != nil {
+		t.Fatalf("Unable to store entry: %v", err)
+	}
+
+	loaded, loadedStore, err := cache.load(reference)
+	if err != nil {
+		t.Fatalf("Unable to load entry: %v", err)
+	}
+	if loaded.Digest != entry.Digest {
+		t.Errorf("Expected %v, got %v", entry.Digest, loaded.Digest)
+	}
+	if _, contents, ok := loadedStore.Get(layer); !ok || string(contents) != "package main" {
+		t.Errorf("Expected cached layer to be loaded, got %q", contents)
+	}
+
+	if err := ioutil.WriteFile(cache.blobPath(layer.Digest), []byte("package evil"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cache.load(reference); err == nil {
+		t.Error("Expected a corrupt layer to be rejected")
+	}
+}
+
+func TestCacheEntryUsable(t *testing.T) {
+	pinned := digest.FromString("manifest")
+	fresh := cacheEntry{Digest: pinned, Fetched: time.Now()}
+	stale := cacheEntry{Digest: pinned, Fetched: time.Now().Add(-2 * time.Hour)}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := Sign(key, pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := cacheEntry{Digest: pinned, Signature: signature, Fetched: time.Now()}
+
+	tests := []struct {
+		name     string
+		entry    cacheEntry
+		policy   Policy
+		keys     []crypto.PublicKey
+		ttl      time.Duration
+		offline  bool
+		expected bool
+	}{
+		{"no ttl", fresh, Policy{}, nil, 0, false, false},
+		{"fresh", fresh, Policy{}, nil, time.Hour, false, true},
+		{"stale", stale, Policy{}, nil, time.Hour, false, false},
+		{"stale offline", stale, Policy{}, nil, time.Hour, true, true},
+		{"pinned", stale, Policy{Digest: pinned.String()}, nil, 0, false, true},
+		{"pinned to another digest", fresh, Policy{Digest: digest.FromString("other").String()}, nil, time.Hour, true, false},
+		{"unverified", fresh, Policy{}, []crypto.PublicKey{key.Public()}, time.Hour, true, false},
+		{"verified", signed, Policy{}, []crypto.PublicKey{key.Public()}, time.Hour, false, true},
+		{"verified with an added key", signed, Policy{}, []crypto.PublicKey{other.Public(), key.Public()}, time.Hour, false, true},
+		{"verified with another key", signed, Policy{}, []crypto.PublicKey{other.Public()}, time.Hour, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := test.entry.usable(test.policy, test.keys, test.ttl, test.offline)
+			if actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deislabs/oras/pkg/content"
+	getter "github.com/hashicorp/go-getter"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// isSourcePath reports whether the repository refers to a git repository,
+// such as git::https://example.com/policies.git//kubernetes?ref=v1.2, or to a
+// local directory rather than to an OCI registry.
+func isSourcePath(repository string) bool {
+	return strings.HasPrefix(repository, "git::") ||
+		strings.HasPrefix(repository, "git@") ||
+		strings.HasPrefix(repository, "./") ||
+		strings.HasPrefix(repository, "../") ||
+		filepath.IsAbs(repository)
+}
+
+// fetchSource clones a git repository or copies a local directory, and returns
+// the policies and data files found in it as layers in a memory store. The
+// digest is computed over the files, so it changes whenever their contents do.
+func fetchSource(ctx context.Context, source string) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, error) {
+	tmp, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// local directories are symlinked into dst, so removing tmp afterwards
+	// leaves them untouched
+	dst := filepath.Join(tmp, "source")
+	client := &getter.Client{
+		Ctx:  ctx,
+		Src:  source,
+		Dst:  dst,
+		Pwd:  pwd,
+		Mode: getter.ClientModeDir,
+	}
+	if err := client.Get(); err != nil {
+		return nil, nil, "", fmt.Errorf("Unable to fetch %s: %v", source, err)
+	}
+
+	memoryStore, layers, err := DirectoryLayers(dst, true)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return memoryStore, layers, layersDigest(layers), nil
+}
+
+// layersDigest returns a digest covering the names and contents of the layers.
+func layersDigest(layers []ocispec.Descriptor) digest.Digest {
+	var entries []string
+	for _, layer := range layers {
+		entries = append(entries, fmt.Sprintf("%s %s\n", layer.Digest, layer.Annotations[ocispec.AnnotationTitle]))
+	}
+	sort.Strings(entries)
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.WriteString(entry)
+	}
+
+	return digest.FromBytes(buf.Bytes())
+}
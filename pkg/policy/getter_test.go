@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestIsSourcePath(t *testing.T) {
+	tests := []struct {
+		repository string
+		expected   bool
+	}{
+		{"git::https://github.com/instrumenta/policies.git//kubernetes?ref=v1.2", true},
+		{"git@github.com:instrumenta/policies.git", true},
+		{"./policies", true},
+		{"../shared/policies", true},
+		{"instrumenta.azurecr.io/test", false},
+		{"instrumenta.azurecr.io/test:v1", false},
+		{"https://bundles.example.com/bundle.tar.gz", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.repository, func(t *testing.T) {
+			if actual := isSourcePath(test.repository); actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFetchSourceFromDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"kubernetes/main.rego":  "package kubernetes",
+		"kubernetes/data.json":  "{}",
+		"README.md":             "not part of the bundle",
+		".git/config.json":      "{}",
+		".github/workflow.yaml": "on: push",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, layers, first, err := fetchSource(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("fetching the directory should not have thrown an error: %v", err)
+	}
+
+	expected := map[string]string{
+		"kubernetes/main.rego": constants.OpenPolicyAgentPolicyLayerMediaType,
+		"kubernetes/data.json": constants.OpenPolicyAgentDataLayerMediaType,
+	}
+	actual := map[string]string{}
+	for _, layer := range layers {
+		actual[layer.Annotations[ocispec.AnnotationTitle]] = layer.MediaType
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected layers %v, got %v", expected, actual)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "kubernetes", "main.rego"), []byte("package kubernetes.changed"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, second, err := fetchSource(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("the digest should change when the contents of the source change")
+	}
+}
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// maxManifestSize limits how much of a manifest is read, manifests are small
+// and anything larger is not a policy bundle
+const maxManifestSize = 4 * 1024 * 1024
+
+// FetchManifest fetches the manifest of a policy bundle without downloading
+// its layers. References without a tag or digest use latest.
+func FetchManifest(ctx context.Context, resolver remotes.Resolver, ref string) (string, digest.Digest, ocispec.Manifest, error) {
+	ref = getRepositoryFromPolicy(Policy{Repository: ref})
+
+	resolved, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", "", ocispec.Manifest{}, RegistryError(ref, fmt.Errorf("Unable to resolve %s: %v", ref, err))
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, resolved)
+	if err != nil {
+		return "", "", ocispec.Manifest{}, err
+	}
+
+	reader, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return "", "", ocispec.Manifest{}, RegistryError(ref, fmt.Errorf("Unable to fetch manifest of %s: %v", ref, err))
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(io.LimitReader(reader, maxManifestSize))
+	if err != nil {
+		return "", "", ocispec.Manifest{}, err
+	}
+	if desc.Digest.Algorithm().FromBytes(contents) != desc.Digest {
+		return "", "", ocispec.Manifest{}, fmt.Errorf("manifest of %s does not match digest %s", ref, desc.Digest)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return "", "", ocispec.Manifest{}, fmt.Errorf("Unable to parse manifest of %s: %v", ref, err)
+	}
+
+	return ref, desc.Digest, manifest, nil
+}
+
+// ListTags lists the tags of a repository using the registry tags API,
+// authenticating the same way the resolver does
+func ListTags(ctx context.Context, options RegistryOptions, repository string) ([]string, error) {
+	spec, err := reference.Parse(removeTag(repository))
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := registryHost(spec.Locator)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(spec.Locator, spec.Hostname()+"/")
+
+	scheme := "https"
+	if options.PlainHTTP {
+		scheme = "http"
+	}
+
+	client, authorizer, err := registryAuth(options, spec.Locator)
+	if err != nil {
+		return nil, err
+	}
+
+	next := fmt.Sprintf("%s://%s/v2/%s/tags/list", scheme, host, name)
+	var all []string
+	for next != "" {
+		page, link, err := listTags(ctx, client, authorizer, next)
+		if err != nil {
+			return nil, RegistryError(repository, fmt.Errorf("Unable to list tags of %s: %v", repository, err))
+		}
+		all = append(all, page...)
+
+		next = ""
+		if link != "" {
+			base, err := url.Parse(fmt.Sprintf("%s://%s", scheme, host))
+			if err != nil {
+				return nil, err
+			}
+			linked, err := base.Parse(link)
+			if err != nil {
+				return nil, err
+			}
+			next = linked.String()
+		}
+	}
+
+	return all, nil
+}
+
+var nextLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+
+// listTags fetches one page of tags, answering the registry's challenge with
+// the authorizer when it asks for credentials, and returns the link to the
+// next page if there is one
+func listTags(ctx context.Context, client *http.Client, authorizer docker.Authorizer, pageURL string) ([]string, string, error) {
+	var responses []*http.Response
+	for {
+		req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := authorizer.Authorize(ctx, req); err != nil {
+			return nil, "", err
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && len(responses) == 0 {
+			resp.Body.Close()
+			responses = append(responses, resp)
+			if err := authorizer.AddResponses(ctx, responses); err != nil {
+				return nil, "", fmt.Errorf("%s returned %s: %v", pageURL, resp.Status, err)
+			}
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("unexpected status code %s: %s", pageURL, resp.Status)
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			return nil, "", err
+		}
+
+		var link string
+		if match := nextLink.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			link = match[1]
+		}
+
+		return page.Tags, link, nil
+	}
+}
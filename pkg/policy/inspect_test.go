@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFetchManifest(t *testing.T) {
+	registry := newMemoryRegistry()
+	pushed := pushTestBundle(t, registry, "localhost:5000/policies:latest", "package main")
+
+	ref, dgst, manifest, err := FetchManifest(context.Background(), registry, "localhost:5000/policies")
+	if err != nil {
+		t.Fatalf("fetching the manifest should not have thrown an error: %v", err)
+	}
+
+	if ref != "localhost:5000/policies:latest" {
+		t.Errorf("Expected %v, got %v", "localhost:5000/policies:latest", ref)
+	}
+	if dgst != pushed.Digest {
+		t.Errorf("Expected %v, got %v", pushed.Digest, dgst)
+	}
+	if len(manifest.Layers) != 1 || manifest.Layers[0].Annotations["org.opencontainers.image.title"] != "main.rego" {
+		t.Errorf("Expected the manifest to list main.rego, got %v", manifest.Layers)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "conftest" || password != "secret" || r.URL.Query().Get("scope") != "repository:policies:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "abc"})
+		case r.Header.Get("Authorization") != "Bearer abc":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test",scope="repository:policies:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/policies/tags/list?last=v1>; rel="next"`)
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"latest", "v1"}})
+		default:
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"v2"}})
+		}
+	}))
+	defer server.Close()
+
+	repository := strings.TrimPrefix(server.URL, "http://") + "/policies"
+	options := RegistryOptions{Username: "conftest", Password: "secret", PlainHTTP: true}
+
+	tags, err := ListTags(context.Background(), options, repository)
+	if err != nil {
+		t.Fatalf("listing tags should not have thrown an error: %v", err)
+	}
+
+	expected := []string{"latest", "v1", "v2"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected %v, got %v", expected, tags)
+	}
+
+	options.Password = "wrong"
+	if _, err := ListTags(context.Background(), options, repository); err == nil || !strings.Contains(err.Error(), RegistryTokenEnv) {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LockFile is the name of the file recording the digests policies resolved to
+const LockFile = "conftest.lock"
+
+// Lock records the exact manifest each configured policy resolved to, so that
+// later runs can download the same policies
+type Lock struct {
+	Policies []Policy `toml:"policies"`
+}
+
+// ReadLock reads the lock file at the given path
+func ReadLock(path string) (Lock, error) {
+	var lock Lock
+	if _, err := toml.DecodeFile(path, &lock); err != nil {
+		return Lock{}, fmt.Errorf("Unable to read lock file %s: %v", path, err)
+	}
+
+	return lock, nil
+}
+
+// WriteLock writes the lock file to the given path
+func WriteLock(path string, lock Lock) error {
+	var buf bytes.Buffer
+	buf.WriteString("# This file is generated by conftest update. Do not edit it by hand.\n\n")
+	if err := toml.NewEncoder(&buf).Encode(lock); err != nil {
+		return fmt.Errorf("Unable to encode lock file: %v", err)
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// LockedPolicies returns the configured policies pinned to the digests in the
+// lock file. It fails if the configuration and the lock file disagree.
+func LockedPolicies(policies []Policy, lock Lock) ([]Policy, error) {
+	if len(policies) != len(lock.Policies) {
+		return nil, fmt.Errorf("lock file has %d policies but the configuration has %d, run conftest update to refresh it", len(lock.Policies), len(policies))
+	}
+
+	var locked []Policy
+	for _, policy := range policies {
+		found := false
+		for _, lockedPolicy := range lock.Policies {
+			if lockedPolicy.Repository == policy.Repository && lockedPolicy.Tag == policy.Tag {
+				if lockedPolicy.Digest == "" {
+					return nil, fmt.Errorf("lock file has no digest for %s", getRepositoryFromPolicy(policy))
+				}
+				if policy.Digest != "" && policy.Digest != lockedPolicy.Digest {
+					return nil, fmt.Errorf("%s is pinned to %s but locked to %s", policy.Repository, policy.Digest, lockedPolicy.Digest)
+				}
+
+				locked = append(locked, lockedPolicy)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%s is not in the lock file, run conftest update to refresh it", getRepositoryFromPolicy(policy))
+		}
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLockRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, LockFile)
+	expected := Lock{
+		Policies: []Policy{
+			{Repository: "my.url.com/repository", Tag: "v1", Digest: "sha256:1234"},
+			{Repository: "my.url.com/other", Digest: "sha256:5678"},
+		},
+	}
+
+	if err := WriteLock(path, expected); err != nil {
+		t.Fatalf("writing the lock file should not have thrown an error: %v", err)
+	}
+
+	actual, err := ReadLock(path)
+	if err != nil {
+		t.Fatalf("reading the lock file should not have thrown an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestLockedPolicies(t *testing.T) {
+	lock := Lock{
+		Policies: []Policy{
+			{Repository: "my.url.com/repository", Tag: "v1", Digest: "sha256:1234"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		policies    []Policy
+		expected    []Policy
+		shouldError bool
+	}{
+		{
+			name:     "policies matching the lock file are pinned",
+			policies: []Policy{{Repository: "my.url.com/repository", Tag: "v1"}},
+			expected: []Policy{{Repository: "my.url.com/repository", Tag: "v1", Digest: "sha256:1234"}},
+		},
+		{
+			name:        "a changed tag is rejected",
+			policies:    []Policy{{Repository: "my.url.com/repository", Tag: "v2"}},
+			shouldError: true,
+		},
+		{
+			name:        "a policy missing from the lock file is rejected",
+			policies:    []Policy{{Repository: "my.url.com/repository", Tag: "v1"}, {Repository: "my.url.com/other"}},
+			shouldError: true,
+		},
+		{
+			name:        "a conflicting pinned digest is rejected",
+			policies:    []Policy{{Repository: "my.url.com/repository", Tag: "v1", Digest: "sha256:abcd"}},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := LockedPolicies(test.policies, lock)
+			if test.shouldError && err == nil {
+				t.Fatal("we expected an error but did not get one")
+			}
+			if !test.shouldError && err != nil {
+				t.Fatalf("we did not expect an error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -2,61 +2,287 @@ package policy
 
 import (
 	"context"
+	"crypto"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/containerd/containerd/log"
-	"github.com/containerd/containerd/remotes/docker"
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
 	"github.com/deislabs/oras/pkg/content"
 	"github.com/deislabs/oras/pkg/oras"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/viper"
-
-	auth "github.com/deislabs/oras/pkg/auth/docker"
 )
 
 // Policy represents a policy
 type Policy struct {
-	Repository string
-	Tag        string
+	Repository string `toml:"repository"`
+	Tag        string `toml:"tag,omitempty"`
+	Digest     string `toml:"digest,omitempty"`
 }
 
-// DownloadPolicy downloads the given policies
-func DownloadPolicy(ctx context.Context, policies []Policy) {
+// DownloadPolicy downloads the given policies and returns them with the
+// manifest digest each one resolved to
+func DownloadPolicy(ctx context.Context, policies []Policy) ([]Policy, error) {
 	policyDir := filepath.Join(".", viper.GetString("policy"))
 	err := os.MkdirAll(policyDir, os.ModePerm)
 	if err != nil {
 		log.G(ctx).Warnf("Error creating policy directory %q: %v\n", policyDir, err)
 	}
 
-	cli, err := auth.NewClient()
+	keys, err := LoadPublicKeys(viper.GetStringSlice("verify-key"))
 	if err != nil {
-		log.G(ctx).Warnf("Error loading auth file: %v\n", err)
+		return nil, fmt.Errorf("Loading verification keys failed: %v", err)
 	}
 
-	resolver, err := cli.Resolver(ctx)
+	dirs, err := sourceDirectories(policies)
 	if err != nil {
-		log.G(ctx).Warnf("Error loading resolver: %v\n", err)
-		resolver = docker.NewResolver(docker.ResolverOptions{})
+		return nil, err
+	}
+
+	var cache *Cache
+	if root, err := DefaultCacheDirectory(); err != nil {
+		log.G(ctx).Warnf("Policy cache is disabled: %v\n", err)
+	} else {
+		cache = NewCache(root)
 	}
 
-	fileStore := content.NewFileStore(policyDir)
-	defer fileStore.Close()
+	// the registry options are only read once a policy is pulled from a
+	// registry, so that they don't affect git, tarball or offline sources
+	var options *RegistryOptions
+	newResolver := func(ref string) (remotes.Resolver, error) {
+		if options == nil {
+			o, err := RegistryOptionsFromConfig(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			options = &o
+		}
 
-	for _, policy := range policies {
-		repository := getRepositoryFromPolicy(policy)
+		return NewResolver(*options, ref)
+	}
+
+	var downloaded []Policy
+	for i, policy := range policies {
+		memoryStore, layers, manifest, err := fetchCachedPolicy(ctx, cache, newResolver, policy, keys)
+		if err != nil {
+			return nil, fmt.Errorf("Downloading policy failed: %v", err)
+		}
+
+		dir := filepath.Join(policyDir, dirs[i])
+		if viper.GetBool("clean") {
+			log.G(ctx).Infof("Removing: %s\n", dir)
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, fmt.Errorf("Cleaning policy directory failed: %v", err)
+			}
+		}
 
-		log.G(ctx).Infof("Downloading: %s\n", repository)
-		_, _, err = oras.Pull(ctx, resolver, repository, fileStore)
+		err = writeLayers(ctx, dir, memoryStore, layers)
 		if err != nil {
-			log.G(ctx).Fatalf("Downloading policy failed: %v\n", err)
+			return nil, fmt.Errorf("Writing policy failed: %v", err)
 		}
+
+		policy.Digest = manifest.String()
+		downloaded = append(downloaded, policy)
+	}
+
+	return downloaded, nil
+}
+
+// newResolverFunc creates the resolver used to pull the given reference
+type newResolverFunc func(ref string) (remotes.Resolver, error)
+
+// fetchCachedPolicy returns the policy from the cache when the cached copy is
+// fresh enough, or when running offline, and fetches it otherwise. Fetched
+// policies are added to the cache.
+func fetchCachedPolicy(ctx context.Context, cache *Cache, newResolver newResolverFunc, policy Policy, keys []crypto.PublicKey) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, error) {
+	reference := cacheReference(policy)
+	offline := viper.GetBool("offline")
+	if cache == nil {
+		if offline {
+			return nil, nil, "", fmt.Errorf("%s cannot be used offline without a policy cache", reference)
+		}
+
+		memoryStore, layers, manifest, _, err := fetchPolicy(ctx, newResolver, policy, keys)
+		return memoryStore, layers, manifest, err
+	}
+
+	entry, memoryStore, err := cache.load(reference)
+	if err == nil && entry.usable(policy, keys, viper.GetDuration("cache-ttl"), offline) {
+		log.G(ctx).Infof("Using cached: %s\n", reference)
+		return memoryStore, entry.Layers, entry.Digest, nil
 	}
+	if err != nil && !os.IsNotExist(err) {
+		log.G(ctx).Warnf("Ignoring cached %s: %v\n", reference, err)
+	}
+	if offline {
+		return nil, nil, "", fmt.Errorf("%s is not in the policy cache, run without --offline to download it", reference)
+	}
+
+	memoryStore, layers, manifest, signature, err := fetchPolicy(ctx, newResolver, policy, keys)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	entry = cacheEntry{
+		Reference: reference,
+		Digest:    manifest,
+		Signature: signature,
+		Fetched:   time.Now(),
+		Layers:    layers,
+	}
+	if err := cache.store(entry, memoryStore); err != nil {
+		log.G(ctx).Warnf("Unable to cache %s: %v\n", reference, err)
+	}
+
+	return memoryStore, layers, manifest, nil
+}
+
+// fetchPolicy fetches a policy from wherever its repository points: an OCI
+// registry, a bundle tarball, a git repository or a local directory. When keys
+// are given, the signature they verified is returned as well.
+func fetchPolicy(ctx context.Context, newResolver newResolverFunc, policy Policy, keys []crypto.PublicKey) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, []byte, error) {
+	var fetch func(context.Context, string) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, error)
+	switch {
+	case IsBundleURL(policy.Repository):
+		fetch = downloadBundle
+	case isSourcePath(policy.Repository):
+		fetch = fetchSource
+	default:
+		return pullPolicy(ctx, newResolver, policy, keys)
+	}
+
+	if len(keys) > 0 {
+		return nil, nil, "", nil, fmt.Errorf("signatures can only be verified for policies from OCI registries, not %s", policy.Repository)
+	}
+
+	log.G(ctx).Infof("Downloading: %s\n", policy.Repository)
+	memoryStore, layers, manifest, err := fetch(ctx, policy.Repository)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	if policy.Digest != "" && policy.Digest != manifest.String() {
+		return nil, nil, "", nil, fmt.Errorf("%s has digest %s but %s was expected", policy.Repository, manifest, policy.Digest)
+	}
+
+	return memoryStore, layers, manifest, nil, nil
+}
+
+// pullPolicy pulls a policy bundle from an OCI registry, verifying its
+// signature first when keys are given.
+func pullPolicy(ctx context.Context, newResolver newResolverFunc, policy Policy, keys []crypto.PublicKey) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, []byte, error) {
+	repository := getRepositoryFromPolicy(policy)
+
+	resolver, err := newResolver(repository)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	pinned, manifest, err := resolvePolicy(ctx, resolver, repository)
+	if err != nil {
+		return nil, nil, "", nil, RegistryError(repository, fmt.Errorf("Unable to resolve %s: %v", repository, err))
+	}
+
+	var signature []byte
+	if len(keys) > 0 {
+		log.G(ctx).Infof("Verifying: %s\n", pinned)
+		signature, err = VerifySignature(ctx, resolver, repository, manifest, keys)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+	}
+
+	log.G(ctx).Infof("Downloading: %s\n", pinned)
+	memoryStore := content.NewMemoryStore()
+	_, layers, err := oras.Pull(ctx, resolver, pinned, memoryStore, oras.WithAllowedMediaTypes(bundleMediaTypes))
+	if err != nil {
+		return nil, nil, "", nil, RegistryError(pinned, err)
+	}
+
+	return memoryStore, layers, manifest, signature, nil
+}
+
+// resolvePolicy resolves the manifest the reference currently points to, and
+// returns a reference pinned to that manifest so that whatever was resolved,
+// and possibly verified, is exactly what gets pulled.
+func resolvePolicy(ctx context.Context, resolver remotes.Resolver, repository string) (string, digest.Digest, error) {
+	_, desc, err := resolver.Resolve(ctx, repository)
+	if err != nil {
+		return "", "", err
+	}
+
+	spec, err := reference.Parse(repository)
+	if err != nil {
+		return "", "", err
+	}
+
+	return spec.Locator + "@" + desc.Digest.String(), desc.Digest, nil
+}
+
+var invalidDirectoryChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// sourceDirectory returns the subdirectory of the policy directory a source
+// is written to. It is derived from the repository without its tag, digest or
+// git ref, so new versions of a source replace the old ones, and ends in a
+// short hash of the cleaned repository so that sources whose names only differ
+// in the characters replaced, such as ./x/p and ../x/p, never collide.
+func sourceDirectory(repository string) string {
+	source := repository
+	for _, prefix := range []string{"git::", "https://", "http://", "file://"} {
+		source = strings.TrimPrefix(source, prefix)
+	}
+	if i := strings.Index(source, "?"); i >= 0 {
+		source = source[:i]
+	}
+	if i := strings.Index(source, "@sha256:"); i >= 0 {
+		source = source[:i]
+	}
+	if !isSourcePath(repository) && !IsBundleURL(repository) {
+		source = removeTag(source)
+	}
+	if strings.HasPrefix(repository, ".") || filepath.IsAbs(repository) {
+		source = filepath.Clean(source)
+	}
+
+	name := invalidDirectoryChars.ReplaceAllString(source, "_")
+	name = strings.Trim(name, "._-")
+	if name == "" {
+		name = "default"
+	}
+
+	return name + "-" + digest.FromString(source).Hex()[:8]
+}
+
+// sourceDirectories returns the subdirectory each policy is written to, and
+// an error when two policies would be written to the same one, such as two
+// tags of the same repository.
+func sourceDirectories(policies []Policy) ([]string, error) {
+	var dirs []string
+	sources := map[string]string{}
+	for _, policy := range policies {
+		dir := sourceDirectory(policy.Repository)
+		if other, ok := sources[dir]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s, only one version of a source can be used", other, policy.Repository, dir)
+		}
+		sources[dir] = policy.Repository
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
 }
 
 func getRepositoryFromPolicy(policy Policy) string {
 	var repository string
-	if repositoryContainsTag(policy.Repository) {
+	if policy.Digest != "" {
+		repository = removeTag(policy.Repository) + "@" + policy.Digest
+	} else if repositoryContainsTag(policy.Repository) {
 		repository = policy.Repository
 	} else if policy.Tag == "" {
 		repository = policy.Repository + ":latest"
@@ -71,3 +297,11 @@ func repositoryContainsTag(repository string) bool {
 	split := strings.Split(repository, "/")
 	return strings.Contains(split[len(split)-1], ":")
 }
+
+func removeTag(repository string) string {
+	if !repositoryContainsTag(repository) {
+		return repository
+	}
+
+	return repository[:strings.LastIndex(repository, ":")]
+}
@@ -1,7 +1,13 @@
 package policy
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestRepositoryToPull(t *testing.T) {
@@ -21,6 +27,14 @@ func TestRepositoryToPull(t *testing.T) {
 			policy:   Policy{Repository: "my.url.com/repository", Tag: "v1"},
 			expected: "my.url.com/repository:v1",
 		},
+		{
+			policy:   Policy{Repository: "my.url.com/repository:v1", Digest: "sha256:1234"},
+			expected: "my.url.com/repository@sha256:1234",
+		},
+		{
+			policy:   Policy{Repository: "localhost:5000/repository", Tag: "v1", Digest: "sha256:1234"},
+			expected: "localhost:5000/repository@sha256:1234",
+		},
 	}
 
 	for _, test := range tests {
@@ -30,3 +44,111 @@ func TestRepositoryToPull(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceDirectory(t *testing.T) {
+	tests := []struct {
+		repository string
+		expected   string
+	}{
+		{"my.url.com/repository", "my.url.com_repository-c1c4e537"},
+		{"my.url.com/repository:v1", "my.url.com_repository-c1c4e537"},
+		{"localhost:5000/repository@sha256:1234", "localhost_5000_repository-b49f0acd"},
+		{"https://bundles.example.com/kubernetes.tar.gz", "bundles.example.com_kubernetes.tar.gz-fb0ced79"},
+		{"git::https://github.com/instrumenta/policies.git//kubernetes?ref=v1.2", "github.com_instrumenta_policies.git_kubernetes-25319265"},
+		{"../shared/policies", "shared_policies-04646e72"},
+		{"../shared/./policies/", "shared_policies-04646e72"},
+		{"..", "default-5ec1f7e7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.repository, func(t *testing.T) {
+			if actual := sourceDirectory(test.repository); actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSourceDirectories(t *testing.T) {
+	tests := []struct {
+		name        string
+		policies    []Policy
+		shouldError bool
+	}{
+		{
+			name: "local paths that only differ in the replaced characters",
+			policies: []Policy{
+				{Repository: "./x/p"},
+				{Repository: "../x/p"},
+			},
+		},
+		{
+			name: "two tags of the same repository",
+			policies: []Policy{
+				{Repository: "my.url.com/repository:v1"},
+				{Repository: "my.url.com/repository:v2"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "two tag fields for the same repository",
+			policies: []Policy{
+				{Repository: "my.url.com/repository", Tag: "v1"},
+				{Repository: "my.url.com/repository", Tag: "v2"},
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dirs, err := sourceDirectories(test.policies)
+			if test.shouldError {
+				if err == nil {
+					t.Errorf("we expected an error but did not get one, got %v", dirs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("we did not expect an error: %v", err)
+			}
+			if len(dirs) != len(test.policies) || dirs[0] == dirs[1] {
+				t.Errorf("Expected a separate directory for each policy, got %v", dirs)
+			}
+		})
+	}
+}
+
+func TestDownloadPolicyReadsRegistryOptionsLazily(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+	if err := os.MkdirAll(source, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(source, "main.rego"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	defer os.Setenv("XDG_CACHE_HOME", cacheHome)
+
+	// a username without a password is an error, but only for registries
+	viper.Set("policy", filepath.Join(dir, "policy"))
+	viper.Set("username", "conftest")
+	defer viper.Set("policy", "")
+	defer viper.Set("username", "")
+
+	ctx := context.Background()
+	if _, err := DownloadPolicy(ctx, []Policy{{Repository: source}}); err != nil {
+		t.Errorf("downloading a local source should not have thrown an error: %v", err)
+	}
+	if _, err := DownloadPolicy(ctx, []Policy{{Repository: "localhost:5000/policies:v1"}}); err == nil {
+		t.Error("we expected an error but did not get one")
+	}
+}
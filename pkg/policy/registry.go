@@ -0,0 +1,215 @@
+package policy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// RegistryTokenEnv is the environment variable holding a token used to
+// authenticate to registries
+const RegistryTokenEnv = "CONFTEST_REGISTRY_TOKEN"
+
+// RegistryOptions configures how conftest connects and authenticates to
+// OCI registries
+type RegistryOptions struct {
+	Username  string
+	Password  string
+	Token     string
+	Insecure  bool
+	PlainHTTP bool
+	CAFile    string
+}
+
+// AddRegistryFlags adds the flags controlling registry access to a command.
+// They are bound to the configuration by BindRegistryFlags.
+func AddRegistryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("username", "", "", "username used to authenticate to the registry")
+	cmd.Flags().BoolP("password-stdin", "", false, "read the registry password from stdin")
+	cmd.Flags().BoolP("insecure", "", false, "skip verifying the registry TLS certificate")
+	cmd.Flags().BoolP("plain-http", "", false, "connect to the registry over plain HTTP")
+	cmd.Flags().StringP("ca-file", "", "", "path to a PEM encoded CA bundle used to verify the registry")
+}
+
+// BindRegistryFlags binds the registry flags of a command to the
+// configuration. It is called before the command runs, so that commands
+// sharing a flag name don't overwrite each other's bindings.
+func BindRegistryFlags(cmd *cobra.Command) {
+	for _, name := range []string{"username", "password-stdin", "insecure", "plain-http", "ca-file"} {
+		viper.BindPFlag(name, cmd.Flags().Lookup(name))
+	}
+}
+
+// RegistryOptionsFromConfig returns the registry options from the
+// configuration and environment, reading the password from stdin when
+// --password-stdin is set
+func RegistryOptionsFromConfig(stdin io.Reader) (RegistryOptions, error) {
+	options := RegistryOptions{
+		Username:  viper.GetString("username"),
+		Token:     os.Getenv(RegistryTokenEnv),
+		Insecure:  viper.GetBool("insecure"),
+		PlainHTTP: viper.GetBool("plain-http"),
+		CAFile:    viper.GetString("ca-file"),
+	}
+
+	if options.Username != "" && !viper.GetBool("password-stdin") && options.Token == "" {
+		return RegistryOptions{}, fmt.Errorf("--username requires --password-stdin or %s", RegistryTokenEnv)
+	}
+
+	if viper.GetBool("password-stdin") {
+		if options.Username == "" {
+			return RegistryOptions{}, fmt.Errorf("--password-stdin requires --username")
+		}
+
+		password, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return RegistryOptions{}, fmt.Errorf("Unable to read password from stdin: %v", err)
+		}
+		options.Password = strings.TrimRight(string(password), "\r\n")
+		if options.Password == "" {
+			return RegistryOptions{}, fmt.Errorf("no password was given on stdin")
+		}
+	}
+
+	return options, nil
+}
+
+// NewResolver creates a resolver for the OCI registry of the given reference.
+// Credentials given in the options are only sent to that registry, any other
+// host the resolver talks to gets the credentials from the Docker config file.
+func NewResolver(options RegistryOptions, ref string) (remotes.Resolver, error) {
+	client, authorizer, err := registryAuth(options, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return docker.NewResolver(docker.ResolverOptions{
+		Authorizer: authorizer,
+		PlainHTTP:  options.PlainHTTP,
+		Client:     client,
+	}), nil
+}
+
+// registryAuth returns the client and the authorizer used to talk to the
+// registry of the given reference, shared by the resolver and the tags API
+func registryAuth(options RegistryOptions, ref string) (*http.Client, docker.Authorizer, error) {
+	registry, err := registryHost(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dockerConfig, err := config.Load(config.Dir())
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to load Docker config: %v", err)
+	}
+
+	client, err := registryClient(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, docker.NewAuthorizer(client, options.credentials(registry, dockerConfig)), nil
+}
+
+// registryHost returns the host of the registry a reference points to, as
+// the resolver sees it
+func registryHost(ref string) (string, error) {
+	spec, err := reference.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	host := spec.Hostname()
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+
+	return host, nil
+}
+
+// credentials returns the username and secret for a registry host. The
+// credentials given in the options are only used for the registry the
+// reference points to. A token is used as the password of the given
+// username, or as an identity token when there is no username.
+func (o RegistryOptions) credentials(registry string, dockerConfig *configfile.ConfigFile) func(string) (string, string, error) {
+	return func(host string) (string, string, error) {
+		if host == registry && o.Password != "" {
+			return o.Username, o.Password, nil
+		}
+		if host == registry && o.Token != "" {
+			return o.Username, o.Token, nil
+		}
+
+		if host == "registry-1.docker.io" {
+			host = "https://index.docker.io/v1/"
+		}
+		auth, err := dockerConfig.GetAuthConfig(host)
+		if err != nil {
+			return "", "", fmt.Errorf("Unable to get credentials for %s from Docker config: %v", host, err)
+		}
+		if auth.IdentityToken != "" {
+			return "", auth.IdentityToken, nil
+		}
+
+		return auth.Username, auth.Password, nil
+	}
+}
+
+func registryClient(options RegistryOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.Insecure}
+	if options.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA bundle: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", options.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   10 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}, nil
+}
+
+// RegistryError turns authentication failures reported by the registry into
+// an error explaining how to provide credentials
+func RegistryError(ref string, err error) error {
+	message := err.Error()
+	if strings.Contains(message, "401 Unauthorized") || strings.Contains(message, "403 Forbidden") {
+		return fmt.Errorf("Authentication to %s failed, provide credentials with --username and --password-stdin, %s or docker login: %v", ref, RegistryTokenEnv, err)
+	}
+
+	return err
+}
@@ -0,0 +1,288 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/viper"
+)
+
+// memoryRegistry is an in-memory stand-in for an OCI registry, implementing
+// just enough of remotes.Resolver for oras to push and pull against it.
+type memoryRegistry struct {
+	mu    sync.Mutex
+	blobs map[digest.Digest][]byte
+	tags  map[string]ocispec.Descriptor
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{
+		blobs: map[digest.Digest][]byte{},
+		tags:  map[string]ocispec.Descriptor{},
+	}
+}
+
+func (r *memoryRegistry) Resolve(ctx context.Context, ref string) (string, ocispec.Descriptor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spec, err := reference.Parse(ref)
+	if err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+
+	if dgst := spec.Digest(); dgst != "" {
+		blob, ok := r.blobs[dgst]
+		if !ok {
+			return "", ocispec.Descriptor{}, errdefs.ErrNotFound
+		}
+		return ref, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: dgst, Size: int64(len(blob))}, nil
+	}
+
+	desc, ok := r.tags[ref]
+	if !ok {
+		return "", ocispec.Descriptor{}, fmt.Errorf("%s: %v", ref, errdefs.ErrNotFound)
+	}
+
+	return ref, desc, nil
+}
+
+func (r *memoryRegistry) Fetcher(ctx context.Context, ref string) (remotes.Fetcher, error) {
+	return &memoryFetcher{registry: r}, nil
+}
+
+func (r *memoryRegistry) Pusher(ctx context.Context, ref string) (remotes.Pusher, error) {
+	return &memoryPusher{registry: r, ref: ref}, nil
+}
+
+type memoryFetcher struct {
+	registry *memoryRegistry
+}
+
+func (f *memoryFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	f.registry.mu.Lock()
+	defer f.registry.mu.Unlock()
+
+	blob, ok := f.registry.blobs[desc.Digest]
+	if !ok {
+		return nil, errdefs.ErrNotFound
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(blob)), nil
+}
+
+type memoryPusher struct {
+	registry *memoryRegistry
+	ref      string
+}
+
+func (p *memoryPusher) Push(ctx context.Context, desc ocispec.Descriptor) (content.Writer, error) {
+	return &memoryWriter{
+		registry:  p.registry,
+		ref:       p.ref,
+		desc:      desc,
+		startedAt: time.Now(),
+	}, nil
+}
+
+type memoryWriter struct {
+	registry  *memoryRegistry
+	ref       string
+	desc      ocispec.Descriptor
+	buf       bytes.Buffer
+	startedAt time.Time
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Close() error {
+	return nil
+}
+
+func (w *memoryWriter) Digest() digest.Digest {
+	return digest.FromBytes(w.buf.Bytes())
+}
+
+func (w *memoryWriter) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...content.Opt) error {
+	if size > 0 && size != int64(w.buf.Len()) {
+		return fmt.Errorf("unexpected commit size %d, expected %d", w.buf.Len(), size)
+	}
+	if expected != "" && expected != w.Digest() {
+		return fmt.Errorf("unexpected commit digest %s, expected %s", w.Digest(), expected)
+	}
+
+	w.registry.mu.Lock()
+	defer w.registry.mu.Unlock()
+
+	w.registry.blobs[w.Digest()] = append([]byte(nil), w.buf.Bytes()...)
+	if w.desc.MediaType == ocispec.MediaTypeImageManifest {
+		w.registry.tags[w.ref] = w.desc
+	}
+
+	return nil
+}
+
+func (w *memoryWriter) Status() (content.Status, error) {
+	return content.Status{
+		Ref:       w.ref,
+		Offset:    int64(w.buf.Len()),
+		Total:     w.desc.Size,
+		Expected:  w.desc.Digest,
+		StartedAt: w.startedAt,
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+func (w *memoryWriter) Truncate(size int64) error {
+	w.buf.Truncate(int(size))
+	return nil
+}
+
+func TestRegistryOptionsFromConfig(t *testing.T) {
+	viper.Set("username", "conftest")
+	viper.Set("password-stdin", true)
+	defer viper.Set("username", "")
+	defer viper.Set("password-stdin", false)
+
+	options, err := RegistryOptionsFromConfig(strings.NewReader("secret\n"))
+	if err != nil {
+		t.Fatalf("Unable to read options: %v", err)
+	}
+	if options.Password != "secret" {
+		t.Errorf("Expected %v, got %v", "secret", options.Password)
+	}
+
+	viper.Set("username", "")
+	if _, err := RegistryOptionsFromConfig(strings.NewReader("secret")); err == nil {
+		t.Error("Expected an error when --password-stdin is given without --username")
+	}
+
+	viper.Set("username", "conftest")
+	viper.Set("password-stdin", false)
+	if _, err := RegistryOptionsFromConfig(strings.NewReader("")); err == nil {
+		t.Error("Expected an error when --username is given without a password")
+	}
+}
+
+func TestRegistryCredentials(t *testing.T) {
+	dockerConfig := configfile.New("")
+	dockerConfig.AuthConfigs["localhost:5000"] = types.AuthConfig{Username: "docker", Password: "config"}
+	dockerConfig.AuthConfigs["auth.example.com"] = types.AuthConfig{Username: "auth", Password: "service"}
+
+	tests := []struct {
+		name     string
+		options  RegistryOptions
+		host     string
+		username string
+		secret   string
+	}{
+		{"docker config", RegistryOptions{}, "localhost:5000", "docker", "config"},
+		{"password", RegistryOptions{Username: "conftest", Password: "secret", Token: "token"}, "localhost:5000", "conftest", "secret"},
+		{"token", RegistryOptions{Token: "token"}, "localhost:5000", "", "token"},
+		{"password for another host", RegistryOptions{Username: "conftest", Password: "secret"}, "auth.example.com", "auth", "service"},
+		{"token for another host", RegistryOptions{Token: "token"}, "redirect.example.com", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			username, secret, err := test.options.credentials("localhost:5000", dockerConfig)(test.host)
+			if err != nil {
+				t.Fatalf("Unable to get credentials: %v", err)
+			}
+			if username != test.username || secret != test.secret {
+				t.Errorf("Expected %v/%v, got %v/%v", test.username, test.secret, username, secret)
+			}
+		})
+	}
+}
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{"localhost:5000/policies:v1", "localhost:5000"},
+		{"instrumenta.azurecr.io/test", "instrumenta.azurecr.io"},
+		{"docker.io/instrumenta/policies@sha256:1234", "registry-1.docker.io"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			actual, err := registryHost(test.ref)
+			if err != nil {
+				t.Fatalf("Unable to get the registry host: %v", err)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRegistryClientCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, bundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := registryClient(RegistryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("Expected an untrusted certificate to be rejected")
+	}
+
+	client, err = registryClient(RegistryOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err != nil {
+		t.Errorf("Expected the CA bundle to be trusted: %v", err)
+	}
+}
+
+func TestRegistryError(t *testing.T) {
+	err := RegistryError("localhost:5000/policies", errors.New("unexpected status code: 401 Unauthorized"))
+	if !strings.Contains(err.Error(), RegistryTokenEnv) {
+		t.Errorf("Expected authentication failures to explain how to log in, got %v", err)
+	}
+
+	other := errors.New("connection refused")
+	if RegistryError("localhost:5000/policies", other) != other {
+		t.Error("Expected other errors to be returned unchanged")
+	}
+}
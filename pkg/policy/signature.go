@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const signatureFileName = "signature"
+
+// LoadPrivateKey reads a PEM encoded RSA or ECDSA private key used to sign
+// policy bundles.
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T in %s", key, path)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, path)
+	}
+}
+
+// LoadPublicKeys reads PEM encoded public keys used to verify policy bundles.
+func LoadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, path := range paths {
+		block, err := readPEM(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse public key %s: %v", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	return block, nil
+}
+
+// Sign creates a detached signature of the given manifest digest.
+func Sign(signer crypto.Signer, manifest digest.Digest) ([]byte, error) {
+	hashed := sha256.Sum256([]byte(manifest))
+	return signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+}
+
+// Verify checks that the signature of the manifest digest was created by one
+// of the given public keys.
+func Verify(keys []crypto.PublicKey, manifest digest.Digest, signature []byte) error {
+	hashed := sha256.Sum256([]byte(manifest))
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, hashed[:], signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			var sig struct {
+				R, S *big.Int
+			}
+			if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+				continue
+			}
+			if ecdsa.Verify(k, hashed[:], sig.R, sig.S) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("signature of %s does not match any of the configured keys", manifest)
+}
+
+// SignatureReference returns the reference under which the signature of the
+// given manifest is stored, next to the signed bundle in the same repository.
+func SignatureReference(ref string, manifest digest.Digest) (string, error) {
+	spec, err := reference.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	tag := strings.Replace(manifest.String(), ":", "-", 1) + ".sig"
+	return spec.Locator + ":" + tag, nil
+}
+
+// PushSignature signs the manifest digest and pushes the signature as a
+// separate artifact alongside the bundle.
+func PushSignature(ctx context.Context, resolver remotes.Resolver, ref string, manifest digest.Digest, signer crypto.Signer) (string, error) {
+	signature, err := Sign(signer, manifest)
+	if err != nil {
+		return "", fmt.Errorf("Unable to sign %s: %v", manifest, err)
+	}
+
+	signatureRef, err := SignatureReference(ref, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	memoryStore := content.NewMemoryStore()
+	layer := memoryStore.Add(signatureFileName, constants.ConftestSignatureLayerMediaType, signature)
+
+	extraOpts := []oras.PushOpt{oras.WithConfigMediaType(constants.OpenPolicyAgentConfigMediaType)}
+	_, err = oras.Push(ctx, resolver, signatureRef, memoryStore, []ocispec.Descriptor{layer}, extraOpts...)
+	if err != nil {
+		return "", err
+	}
+
+	return signatureRef, nil
+}
+
+// VerifySignature fetches the signature stored alongside the manifest and
+// checks it against the given public keys. The signature that matched is
+// returned, so that it can be checked again later without fetching it.
+func VerifySignature(ctx context.Context, resolver remotes.Resolver, ref string, manifest digest.Digest, keys []crypto.PublicKey) ([]byte, error) {
+	signatureRef, err := SignatureReference(ref, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryStore := content.NewMemoryStore()
+	_, layers, err := oras.Pull(ctx, resolver, signatureRef, memoryStore, oras.WithAllowedMediaTypes([]string{constants.ConftestSignatureLayerMediaType}))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch signature %s: %v", signatureRef, err)
+	}
+
+	for _, layer := range layers {
+		_, signature, ok := memoryStore.Get(layer)
+		if !ok {
+			continue
+		}
+		if err := Verify(keys, manifest, signature); err == nil {
+			return signature, nil
+		}
+	}
+
+	return nil, errors.New("no valid signature found for " + ref)
+}
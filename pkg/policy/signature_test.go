@@ -0,0 +1,227 @@
+package policy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := digest.FromString("manifest")
+
+	tests := []struct {
+		name        string
+		signer      crypto.Signer
+		keys        []crypto.PublicKey
+		shouldError bool
+	}{
+		{
+			name:   "ECDSA signature verified by matching key",
+			signer: ecdsaKey,
+			keys:   []crypto.PublicKey{ecdsaKey.Public()},
+		},
+		{
+			name:   "RSA signature verified by one of several keys",
+			signer: rsaKey,
+			keys:   []crypto.PublicKey{otherKey.Public(), rsaKey.Public()},
+		},
+		{
+			name:        "signature rejected by an unrelated key",
+			signer:      ecdsaKey,
+			keys:        []crypto.PublicKey{otherKey.Public()},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature, err := Sign(test.signer, manifest)
+			if err != nil {
+				t.Fatalf("signing should not have thrown an error: %v", err)
+			}
+
+			err = Verify(test.keys, manifest, signature)
+			if test.shouldError && err == nil {
+				t.Error("we expected verification to fail but it did not")
+			}
+			if !test.shouldError && err != nil {
+				t.Errorf("we did not expect verification to fail: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeyPath, publicKeyPath := writeKeyPair(t, dir, key)
+
+	signer, err := LoadPrivateKey(privateKeyPath)
+	if err != nil {
+		t.Fatalf("loading the private key should not have thrown an error: %v", err)
+	}
+	keys, err := LoadPublicKeys([]string{publicKeyPath})
+	if err != nil {
+		t.Fatalf("loading the public key should not have thrown an error: %v", err)
+	}
+
+	manifest := digest.FromString("manifest")
+	signature, err := Sign(signer, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(keys, manifest, signature); err != nil {
+		t.Errorf("signature from the loaded private key should verify with the loaded public key: %v", err)
+	}
+}
+
+func TestVerifySignatureFromRegistry(t *testing.T) {
+	ctx := context.Background()
+	registry := newMemoryRegistry()
+	ref := "localhost:5000/policies:v1"
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []crypto.PublicKey{key.Public()}
+
+	signed := pushTestBundle(t, registry, ref, "package main")
+	if _, err := PushSignature(ctx, registry, ref, signed.Digest, key); err != nil {
+		t.Fatalf("pushing the signature should not have thrown an error: %v", err)
+	}
+
+	pinned, manifest, err := resolvePolicy(ctx, registry, ref)
+	if err != nil {
+		t.Fatalf("resolving the bundle should not have thrown an error: %v", err)
+	}
+	if expected := "localhost:5000/policies@" + signed.Digest.String(); pinned != expected {
+		t.Errorf("Expected the resolved reference to be pinned to %v, got %v", expected, pinned)
+	}
+	signature, err := VerifySignature(ctx, registry, ref, manifest, keys)
+	if err != nil {
+		t.Errorf("the signed bundle should verify: %v", err)
+	}
+	if err := Verify(keys, manifest, signature); err != nil {
+		t.Errorf("the returned signature should verify: %v", err)
+	}
+
+	// a bundle pushed over the same tag without a signature must be rejected
+	pushTestBundle(t, registry, ref, "package relaxed")
+	_, manifest, err = resolvePolicy(ctx, registry, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifySignature(ctx, registry, ref, manifest, keys); err == nil {
+		t.Error("an unsigned bundle should not verify")
+	}
+}
+
+func TestFetchPolicyVerifiesSignature(t *testing.T) {
+	ctx := context.Background()
+	registry := newMemoryRegistry()
+	newResolver := func(string) (remotes.Resolver, error) {
+		return registry, nil
+	}
+	policy := Policy{Repository: "localhost:5000/policies:v1"}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := pushTestBundle(t, registry, policy.Repository, "package main")
+	if _, err := PushSignature(ctx, registry, policy.Repository, signed.Digest, key); err != nil {
+		t.Fatalf("pushing the signature should not have thrown an error: %v", err)
+	}
+
+	_, layers, manifest, signature, err := fetchPolicy(ctx, newResolver, policy, []crypto.PublicKey{key.Public()})
+	if err != nil {
+		t.Fatalf("fetching the signed bundle should not have thrown an error: %v", err)
+	}
+	if manifest != signed.Digest || len(layers) != 1 || len(signature) == 0 {
+		t.Errorf("Expected the signed bundle %s with its signature, got %s with %d layers", signed.Digest, manifest, len(layers))
+	}
+
+	if _, _, _, _, err := fetchPolicy(ctx, newResolver, policy, []crypto.PublicKey{otherKey.Public()}); err == nil {
+		t.Error("a bundle signed by another key should not be fetched")
+	}
+}
+
+func pushTestBundle(t *testing.T, registry *memoryRegistry, ref string, policy string) ocispec.Descriptor {
+	memoryStore := content.NewMemoryStore()
+	layer := memoryStore.Add("main.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte(policy))
+
+	manifest, err := oras.Push(context.Background(), registry, ref, memoryStore, []ocispec.Descriptor{layer})
+	if err != nil {
+		t.Fatalf("pushing the bundle should not have thrown an error: %v", err)
+	}
+
+	return manifest
+}
+
+func writeKeyPair(t *testing.T, dir string, key *ecdsa.PrivateKey) (string, string) {
+	privateBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKeyPath := filepath.Join(dir, "key.pem")
+	publicKeyPath := filepath.Join(dir, "key.pub")
+	err = ioutil.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateBytes}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return privateKeyPath, publicKeyPath
+}
@@ -0,0 +1,280 @@
+package policy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/open-policy-agent/opa/ast"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// maxBundleSize is the largest bundle tarball that is downloaded, and the
+// largest total size of the files extracted from it. It guards against
+// hostile servers and gzip bombs exhausting memory.
+var maxBundleSize int64 = 64 << 20
+
+// IsBundleURL reports whether the repository refers to an Open Policy Agent
+// bundle tarball rather than to an OCI registry.
+func IsBundleURL(repository string) bool {
+	for _, scheme := range []string{"https://", "http://", "file://"} {
+		if strings.HasPrefix(repository, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// downloadBundle fetches an Open Policy Agent bundle tarball over HTTP or from
+// the local filesystem. The policies, data and manifest in the bundle are
+// returned as layers in a memory store, alongside the digest of the tarball.
+func downloadBundle(ctx context.Context, source string) (*content.Memorystore, []ocispec.Descriptor, digest.Digest, error) {
+	archive, err := fetchBundle(ctx, source)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	memoryStore, layers, err := extractBundle(archive)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("Invalid bundle %s: %v", source, err)
+	}
+
+	return memoryStore, layers, digest.FromBytes(archive), nil
+}
+
+func fetchBundle(ctx context.Context, source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "file" {
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return readLimited(f, maxBundleSize, source)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to download %s: %s", source, resp.Status)
+	}
+
+	return readLimited(resp.Body, maxBundleSize, source)
+}
+
+// readLimited reads r to the end, returning an error when it holds more than
+// limit bytes
+func readLimited(r io.Reader, limit int64, name string) ([]byte, error) {
+	contents, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > limit {
+		return nil, fmt.Errorf("%s is larger than the limit of %d bytes", name, limit)
+	}
+
+	return contents, nil
+}
+
+// extractBundle reads the .rego files, data.json files and manifest from a
+// gzipped bundle tarball, checking that everything falls within the roots
+// declared by the manifest.
+func extractBundle(archive []byte) (*content.Memorystore, []ocispec.Descriptor, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	memoryStore := content.NewMemoryStore()
+	var layers []ocispec.Descriptor
+	var manifest *Manifest
+	var packages []string
+	remaining := maxBundleSize
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeSymlink, tar.TypeLink:
+			return nil, nil, fmt.Errorf("%s is a link, bundles must only contain regular files", header.Name)
+		default:
+			return nil, nil, fmt.Errorf("%s is not a regular file", header.Name)
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		contents, err := readLimited(tr, remaining, "the extracted bundle")
+		if err != nil {
+			return nil, nil, err
+		}
+		remaining -= int64(len(contents))
+
+		switch {
+		case name == ManifestFileName:
+			manifest = &Manifest{}
+			if err := json.Unmarshal(contents, manifest); err != nil {
+				return nil, nil, fmt.Errorf("Unable to parse %s: %v", ManifestFileName, err)
+			}
+			layers = append(layers, memoryStore.Add(name, constants.OpenPolicyAgentManifestLayerMediaType, contents))
+		case path.Ext(name) == ".rego":
+			module, err := ast.ParseModule(name, string(contents))
+			if err != nil {
+				return nil, nil, err
+			}
+			packages = append(packages, PackageRoot(module))
+			layers = append(layers, memoryStore.Add(name, constants.OpenPolicyAgentPolicyLayerMediaType, contents))
+		case path.Base(name) == "data.json":
+			dir := path.Dir(name)
+			if dir == "." {
+				dir = ""
+			}
+			packages = append(packages, dir)
+			layers = append(layers, memoryStore.Add(name, constants.OpenPolicyAgentDataLayerMediaType, contents))
+		}
+	}
+
+	if len(packages) == 0 {
+		return nil, nil, fmt.Errorf("no policies or data files found")
+	}
+
+	if manifest != nil && len(manifest.Roots) > 0 {
+		for _, p := range packages {
+			if !withinRoots(p, manifest.Roots) {
+				return nil, nil, fmt.Errorf("%q is outside of the bundle roots %v", p, manifest.Roots)
+			}
+		}
+	}
+
+	return memoryStore, layers, nil
+}
+
+// PushBundle writes the layers as an Open Policy Agent bundle tarball to a
+// file:// URL, or uploads it with a PUT request to an http:// or https://
+// URL. The digest of the tarball is returned.
+func PushBundle(ctx context.Context, destination string, memoryStore *content.Memorystore, layers []ocispec.Descriptor) (digest.Digest, error) {
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, memoryStore, layers); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "file" {
+		if err := ioutil.WriteFile(u.Path, buf.Bytes(), 0644); err != nil {
+			return "", err
+		}
+		return digest.FromBytes(buf.Bytes()), nil
+	}
+
+	req, err := http.NewRequest(http.MethodPut, destination, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("Unable to upload %s: %s", destination, resp.Status)
+	}
+
+	return digest.FromBytes(buf.Bytes()), nil
+}
+
+// WriteBundle writes the layers as a gzipped bundle tarball, naming each file
+// after the title of its layer. Files are written in order of their names,
+// without timestamps, so the same layers always produce the same tarball.
+func WriteBundle(w io.Writer, memoryStore *content.Memorystore, layers []ocispec.Descriptor) error {
+	sorted := append([]ocispec.Descriptor{}, layers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Annotations[ocispec.AnnotationTitle] < sorted[j].Annotations[ocispec.AnnotationTitle]
+	})
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, layer := range sorted {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		_, contents, ok := memoryStore.Get(layer)
+		if !ok || name == "" {
+			return fmt.Errorf("layer %s is missing from the bundle", layer.Digest)
+		}
+
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// withinRoots reports whether the slash separated path is owned by one of the
+// roots, following the Open Policy Agent bundle rules.
+func withinRoots(p string, roots []string) bool {
+	for _, root := range roots {
+		if root == "" || p == root || strings.HasPrefix(p, root+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,306 @@
+package policy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/instrumenta/conftest/pkg/constants"
+
+	"github.com/deislabs/oras/pkg/content"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestDownloadBundle(t *testing.T) {
+	archive := buildTarball(t, map[string]string{
+		".manifest":                  `{"revision": "1", "roots": ["kubernetes"]}`,
+		"kubernetes/main.rego":       "package kubernetes.main",
+		"kubernetes/data.json":       `{"allowed": []}`,
+		"kubernetes/README.md":       "not part of the bundle",
+		"kubernetes/main_test.rego":  "package kubernetes.main",
+		"kubernetes/lib/labels.rego": "package kubernetes.lib",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bundle.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	local := filepath.Join(dir, "bundle.tar.gz")
+	if err := ioutil.WriteFile(local, archive, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		".manifest":                  constants.OpenPolicyAgentManifestLayerMediaType,
+		"kubernetes/main.rego":       constants.OpenPolicyAgentPolicyLayerMediaType,
+		"kubernetes/main_test.rego":  constants.OpenPolicyAgentPolicyLayerMediaType,
+		"kubernetes/lib/labels.rego": constants.OpenPolicyAgentPolicyLayerMediaType,
+		"kubernetes/data.json":       constants.OpenPolicyAgentDataLayerMediaType,
+	}
+
+	for _, source := range []string{server.URL + "/bundle.tar.gz", "file://" + filepath.ToSlash(local)} {
+		t.Run(source, func(t *testing.T) {
+			_, layers, manifest, err := downloadBundle(context.Background(), source)
+			if err != nil {
+				t.Fatalf("downloading the bundle should not have thrown an error: %v", err)
+			}
+
+			if manifest != digest.FromBytes(archive) {
+				t.Errorf("Expected digest %v, got %v", digest.FromBytes(archive), manifest)
+			}
+
+			actual := map[string]string{}
+			for _, layer := range layers {
+				actual[layer.Annotations[ocispec.AnnotationTitle]] = layer.MediaType
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected layers %v, got %v", expected, actual)
+			}
+		})
+	}
+
+	t.Run("missing bundles are an error", func(t *testing.T) {
+		if _, _, _, err := downloadBundle(context.Background(), server.URL+"/missing.tar.gz"); err == nil {
+			t.Error("we expected an error but did not get one")
+		}
+	})
+}
+
+func TestExtractBundleRoots(t *testing.T) {
+	archive := buildTarball(t, map[string]string{
+		".manifest":      `{"roots": ["kubernetes"]}`,
+		"terraform.rego": "package terraform",
+	})
+
+	if _, _, err := extractBundle(archive); err == nil {
+		t.Error("a policy outside of the bundle roots should be rejected")
+	}
+}
+
+func TestExtractBundleEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     []*tar.Header
+		shouldError bool
+	}{
+		{
+			name: "directories are skipped",
+			headers: []*tar.Header{
+				{Name: "kubernetes/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "kubernetes/main.rego", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("package kubernetes.main"))},
+			},
+		},
+		{
+			name: "symbolic links are rejected",
+			headers: []*tar.Header{
+				{Name: "kubernetes/main.rego", Typeflag: tar.TypeSymlink, Linkname: "../shared/main.rego"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "hard links are rejected",
+			headers: []*tar.Header{
+				{Name: "kubernetes/main.rego", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("package kubernetes.main"))},
+				{Name: "kubernetes/copy.rego", Typeflag: tar.TypeLink, Linkname: "kubernetes/main.rego"},
+			},
+			shouldError: true,
+		},
+		{
+			name: "bundles without policies or data are rejected",
+			headers: []*tar.Header{
+				{Name: "kubernetes/", Typeflag: tar.TypeDir, Mode: 0755},
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gz)
+			for _, header := range test.headers {
+				if err := tw.WriteHeader(header); err != nil {
+					t.Fatal(err)
+				}
+				if header.Size > 0 {
+					if _, err := tw.Write([]byte("package kubernetes.main")); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err := extractBundle(buf.Bytes())
+			if test.shouldError && err == nil {
+				t.Error("we expected an error but did not get one")
+			}
+			if !test.shouldError && err != nil {
+				t.Errorf("we did not expect an error: %v", err)
+			}
+		})
+	}
+}
+
+func TestBundleSizeLimit(t *testing.T) {
+	archive := buildTarball(t, map[string]string{
+		"main.rego": "package main\n\n" + strings.Repeat("# padding\n", 100),
+	})
+
+	limit := maxBundleSize
+	defer func() { maxBundleSize = limit }()
+
+	maxBundleSize = int64(len(archive))
+	if _, _, err := extractBundle(archive); err == nil {
+		t.Error("a bundle whose files extract to more than the limit should be rejected")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	maxBundleSize = int64(len(archive)) - 1
+	if _, _, _, err := downloadBundle(context.Background(), server.URL+"/bundle.tar.gz"); err == nil {
+		t.Error("a download larger than the limit should be rejected")
+	}
+}
+
+func TestPushBundle(t *testing.T) {
+	memoryStore := content.NewMemoryStore()
+	layers := []ocispec.Descriptor{
+		memoryStore.Add("kubernetes/main.rego", constants.OpenPolicyAgentPolicyLayerMediaType, []byte("package kubernetes.main")),
+		memoryStore.Add("kubernetes/data.json", constants.OpenPolicyAgentDataLayerMediaType, []byte(`{"allowed": []}`)),
+		memoryStore.Add(ManifestFileName, constants.OpenPolicyAgentManifestLayerMediaType, []byte(`{"roots":["kubernetes"]}`)),
+	}
+
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/bundle.tar.gz" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		uploaded, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "conftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "bundle.tar.gz")
+
+	expected := map[string]string{
+		".manifest":            constants.OpenPolicyAgentManifestLayerMediaType,
+		"kubernetes/main.rego": constants.OpenPolicyAgentPolicyLayerMediaType,
+		"kubernetes/data.json": constants.OpenPolicyAgentDataLayerMediaType,
+	}
+
+	tests := []struct {
+		destination string
+		archive     func() []byte
+	}{
+		{
+			destination: server.URL + "/bundle.tar.gz",
+			archive:     func() []byte { return uploaded },
+		},
+		{
+			destination: "file://" + filepath.ToSlash(local),
+			archive: func() []byte {
+				archive, _ := ioutil.ReadFile(local)
+				return archive
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.destination, func(t *testing.T) {
+			tarball, err := PushBundle(context.Background(), test.destination, memoryStore, layers)
+			if err != nil {
+				t.Fatalf("pushing the bundle should not have thrown an error: %v", err)
+			}
+
+			archive := test.archive()
+			if tarball != digest.FromBytes(archive) {
+				t.Errorf("Expected digest %v, got %v", digest.FromBytes(archive), tarball)
+			}
+
+			_, extracted, err := extractBundle(archive)
+			if err != nil {
+				t.Fatalf("the pushed bundle should be readable: %v", err)
+			}
+
+			actual := map[string]string{}
+			for _, layer := range extracted {
+				actual[layer.Annotations[ocispec.AnnotationTitle]] = layer.MediaType
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected layers %v, got %v", expected, actual)
+			}
+		})
+	}
+
+	t.Run("failed uploads are an error", func(t *testing.T) {
+		if _, err := PushBundle(context.Background(), server.URL+"/other.tar.gz", memoryStore, layers); err == nil {
+			t.Error("we expected an error but did not get one")
+		}
+	})
+}
+
+func buildTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, contents := range files {
+		header := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}